@@ -95,3 +95,27 @@ func GetAccount(accNum int) (*common.Address, *ecdsa.PrivateKey) {
 
 	return &address, priv
 }
+
+// Load reads the key of an existing account without printing anything. Unlike
+// GetAccount it never generates a key, for long running services.
+func Load(accNum int) (common.Address, *ecdsa.PrivateKey, error) {
+	var keyFilePath string
+	switch accNum {
+	case 1:
+		keyFilePath = filepath.Join(getPath(), key1FilePath)
+	case 2:
+		keyFilePath = filepath.Join(getPath(), key2FilePath)
+	default:
+		return common.Address{}, nil, fmt.Errorf("invalid account number %d, use 1 or 2", accNum)
+	}
+
+	keyHex, err := os.ReadFile(keyFilePath)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	priv, err := crypto.HexToECDSA(strings.TrimSpace(string(keyHex)))
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("invalid key in %s: %w", keyFilePath, err)
+	}
+	return crypto.PubkeyToAddress(priv.PublicKey), priv, nil
+}
@@ -0,0 +1,239 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// AccessListResult compares a call's gas with and without the access list the
+// node generated for it.
+type AccessListResult struct {
+	AccessList types.AccessList
+	GasWithout uint64 // estimated gas without an access list
+	GasWith    uint64 // estimated gas with AccessList attached
+	ListCost   uint64 // intrinsic gas charged for the list itself
+}
+
+// Saving is the gas saved by attaching the access list, negative if it costs more.
+func (r *AccessListResult) Saving() int64 {
+	return int64(r.GasWithout) - int64(r.GasWith)
+}
+
+// Attach reports whether attaching the access list pays off.
+func (r *AccessListResult) Attach() bool {
+	return len(r.AccessList) > 0 && r.Saving() > 0
+}
+
+// Gas returns the gas limit to use for the chosen variant.
+func (r *AccessListResult) Gas() uint64 {
+	if r.Attach() {
+		return r.GasWith
+	}
+	return r.GasWithout
+}
+
+// Chosen returns the access list to put into the transaction, nil if it
+// doesn't save gas.
+func (r *AccessListResult) Chosen() types.AccessList {
+	if r.Attach() {
+		return r.AccessList
+	}
+	return nil
+}
+
+func (r *AccessListResult) String() string {
+	addresses, keys := len(r.AccessList), r.AccessList.StorageKeys()
+	s := fmt.Sprintf("access list with %d address(es) and %d storage key(s) costs %d gas up front\n", addresses, keys, r.ListCost)
+	s += fmt.Sprintf("  estimated gas without list: %d, with list: %d", r.GasWithout, r.GasWith)
+	switch saving := r.Saving(); {
+	case r.Attach():
+		s += fmt.Sprintf(" -> attaching, saves %d gas", saving)
+	case len(r.AccessList) == 0:
+		s += " -> nothing to pre-warm"
+	default:
+		s += fmt.Sprintf(" -> not attaching, would cost %d extra gas", -saving)
+	}
+	return s
+}
+
+// AccessListCost is the intrinsic gas of an access list: 2400 per address and
+// 1900 per storage key (EIP-2930).
+func AccessListCost(al types.AccessList) uint64 {
+	return uint64(len(al))*params.TxAccessListAddressGas + uint64(al.StorageKeys())*params.TxAccessListStorageKeyGas
+}
+
+// CreateAccessList asks the node for the access list of msg through
+// eth_createAccessList and estimates the call's gas with and without it.
+// Pre-warming saves 2500 gas per address and 2000 per slot on first access,
+// which the list itself has to pay back.
+func CreateAccessList(ctx context.Context, client *ethclient.Client, msg ethereum.CallMsg) (*AccessListResult, error) {
+	return createAccessList(ctx, client, msg, nil)
+}
+
+// createAccessList is CreateAccessList, dropping keyless entries for the
+// given warm addresses before the list is priced.
+func createAccessList(ctx context.Context, client *ethclient.Client, msg ethereum.CallMsg, warm []common.Address) (*AccessListResult, error) {
+	msg.AccessList = nil
+	al, _, vmErr, err := gethclient.New(client.Client()).CreateAccessList(ctx, msg)
+	if err != nil {
+		return nil, fmt.Errorf("eth_createAccessList failed: %w", err)
+	}
+	if vmErr != "" {
+		return nil, fmt.Errorf("call reverted while creating access list: %s", vmErr)
+	}
+	if al == nil {
+		return nil, errors.New("eth_createAccessList returned no access list")
+	}
+
+	list := PruneWarmAddresses(*al, warm)
+	result := &AccessListResult{AccessList: list, ListCost: AccessListCost(list)}
+	if result.GasWithout, err = client.EstimateGas(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to estimate gas without access list: %w", err)
+	}
+	if len(result.AccessList) == 0 {
+		result.GasWith = result.GasWithout
+		return result, nil
+	}
+	msg.AccessList = result.AccessList
+	if result.GasWith, err = client.EstimateGas(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to estimate gas with access list: %w", err)
+	}
+	return result, nil
+}
+
+// Env is the chain context an access list is checked against.
+type Env struct {
+	Rules    params.Rules
+	Coinbase common.Address
+}
+
+// EnvAt returns the environment of the block after head. Networks without a
+// go-ethereum config (cfg == nil) are assumed to run the latest forks.
+func EnvAt(cfg *params.ChainConfig, head *types.Header) Env {
+	if cfg == nil {
+		cfg = params.MergedTestChainConfig
+	}
+	number := new(big.Int).Add(head.Number, big.NewInt(1))
+	return Env{
+		Rules:    cfg.Rules(number, true, head.Time),
+		Coinbase: head.Coinbase,
+	}
+}
+
+// WarmAddresses returns the addresses that are warm before execution starts
+// (EIP-2929): sender, recipient and precompiles, plus the coinbase from
+// Shanghai on (EIP-3651). Listing them without storage keys only costs gas.
+func WarmAddresses(from common.Address, to *common.Address, env Env) []common.Address {
+	warm := append([]common.Address{from}, vm.ActivePrecompiles(env.Rules)...)
+	if to != nil {
+		warm = append(warm, *to)
+	}
+	if env.Rules.IsShanghai {
+		warm = append(warm, env.Coinbase)
+	}
+	return warm
+}
+
+// warmAddresses is WarmAddresses for the request. A contract creation warms
+// the address of the new contract instead of a recipient.
+func (r *Request) warmAddresses(env Env) []common.Address {
+	warm := WarmAddresses(r.From, r.To, env)
+	if r.IsCreate() {
+		warm = append(warm, crypto.CreateAddress(r.From, r.Nonce))
+	}
+	return warm
+}
+
+// ValidateAccessList rejects duplicate addresses, duplicate storage keys and
+// precompiles, which are always warm and have no storage.
+func ValidateAccessList(al types.AccessList, env Env) error {
+	precompiles := make(map[common.Address]bool)
+	for _, addr := range vm.ActivePrecompiles(env.Rules) {
+		precompiles[addr] = true
+	}
+
+	seen := make(map[common.Address]bool, len(al))
+	for _, entry := range al {
+		if precompiles[entry.Address] {
+			return fmt.Errorf("access list contains precompile %s", entry.Address.Hex())
+		}
+		if seen[entry.Address] {
+			return fmt.Errorf("access list contains %s twice", entry.Address.Hex())
+		}
+		seen[entry.Address] = true
+
+		keys := make(map[common.Hash]bool, len(entry.StorageKeys))
+		for _, key := range entry.StorageKeys {
+			if keys[key] {
+				return fmt.Errorf("access list contains storage key %s of %s twice", key.Hex(), entry.Address.Hex())
+			}
+			keys[key] = true
+		}
+	}
+	return nil
+}
+
+// PruneWarmAddresses drops entries for already warm addresses that list no
+// storage keys, each of which would cost 2400 gas for nothing.
+func PruneWarmAddresses(al types.AccessList, warm []common.Address) types.AccessList {
+	isWarm := make(map[common.Address]bool, len(warm))
+	for _, addr := range warm {
+		isWarm[addr] = true
+	}
+
+	var pruned types.AccessList
+	for _, entry := range al {
+		if isWarm[entry.Address] && len(entry.StorageKeys) == 0 {
+			continue
+		}
+		pruned = append(pruned, entry)
+	}
+	return pruned
+}
+
+// SetAccessList validates al, drops entries made redundant by warm addresses
+// and attaches it to the request.
+func (r *Request) SetAccessList(al types.AccessList, env Env) error {
+	if r.Type == types.LegacyTxType && len(al) > 0 {
+		return errors.New("legacy transactions cannot carry an access list")
+	}
+	if err := ValidateAccessList(al, env); err != nil {
+		return err
+	}
+	r.AccessList = PruneWarmAddresses(al, r.warmAddresses(env))
+	return nil
+}
+
+// GenerateAccessList creates an access list for the request with
+// eth_createAccessList and attaches it if it saves gas. If the request has no
+// gas limit yet, the estimate of the chosen variant is used.
+func (r *Request) GenerateAccessList(ctx context.Context, client *ethclient.Client, env Env) (*AccessListResult, error) {
+	if r.Type == types.LegacyTxType {
+		return nil, errors.New("legacy transactions cannot carry an access list")
+	}
+	msg := r.CallMsg()
+	msg.Gas = 0
+	result, err := createAccessList(ctx, client, msg, r.warmAddresses(env))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.SetAccessList(result.Chosen(), env); err != nil {
+		return nil, err
+	}
+	if r.Gas == 0 {
+		r.Gas = result.Gas()
+	}
+	return result, nil
+}
@@ -0,0 +1,179 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Loss is a field of the source that the target type cannot carry.
+type Loss struct {
+	Field  string
+	Reason string
+}
+
+func (l Loss) String() string {
+	return l.Field + ": " + l.Reason
+}
+
+// RequestFromTx turns a transaction back into a request. from is the sender,
+// which the transaction itself only holds implicitly in its signature.
+func RequestFromTx(tx *types.Transaction, from common.Address) *Request {
+	r := &Request{
+		Type:       tx.Type(),
+		ChainID:    tx.ChainId(),
+		From:       from,
+		To:         tx.To(),
+		Nonce:      tx.Nonce(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		Gas:        tx.Gas(),
+		AccessList: tx.AccessList(),
+		BlobHashes: tx.BlobHashes(),
+		Sidecar:    tx.BlobTxSidecar(),
+		AuthList:   tx.SetCodeAuthorizations(),
+	}
+	switch tx.Type() {
+	case types.LegacyTxType, types.AccessListTxType:
+		r.GasPrice = tx.GasPrice()
+	default:
+		r.GasTipCap, r.GasFeeCap = tx.GasTipCap(), tx.GasFeeCap()
+	}
+	if tx.Type() == types.BlobTxType {
+		r.BlobFeeCap = tx.BlobGasFeeCap()
+	}
+	return r
+}
+
+// Convert re-expresses the request as a transaction of type target. The
+// result needs a new signature, and fields only the target type has (blob
+// fields, authorizations) must already be set on r.
+//
+// Fees are mapped so the sender never pays more than before:
+//   - GasPrice to caps: GasFeeCap is the old GasPrice. GasTipCap is what is
+//     left of it above baseFee, or the whole GasPrice if baseFee is nil, which
+//     prices exactly like the legacy transaction.
+//   - Caps to GasPrice: the price the transaction would pay at baseFee,
+//     min(GasFeeCap, baseFee+GasTipCap), or GasFeeCap if baseFee is nil.
+//
+// Fields the target cannot carry are dropped and returned as losses.
+// Authorizations that are new in the target add their intrinsic gas to Gas;
+// otherwise Gas is kept as is.
+func (r *Request) Convert(target uint8, baseFee *big.Int) (*Request, []Loss, error) {
+	out := *r
+	out.Type = target
+	var losses []Loss
+
+	// Type specific fields the target must have
+	switch target {
+	case types.LegacyTxType, types.AccessListTxType, types.DynamicFeeTxType:
+	case types.BlobTxType:
+		if len(r.BlobHashes) == 0 || r.BlobFeeCap == nil {
+			return nil, nil, errors.New("converting to a blob transaction needs BlobHashes, BlobFeeCap and a sidecar")
+		}
+	case types.SetCodeTxType:
+		if len(r.AuthList) == 0 {
+			return nil, nil, errors.New("converting to a set code transaction needs at least one authorization in AuthList")
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported transaction type %d", target)
+	}
+	if target != types.LegacyTxType && (r.ChainID == nil || r.ChainID.Sign() == 0) {
+		return nil, nil, errors.New("typed transactions need a chain ID, the source is unprotected")
+	}
+	if r.IsCreate() && (target == types.BlobTxType || target == types.SetCodeTxType) {
+		return nil, nil, fmt.Errorf("%w: the source deploys a contract", ErrCreationNotAllowed)
+	}
+
+	// Fees
+	fromPrice := r.Type == types.LegacyTxType || r.Type == types.AccessListTxType
+	toPrice := target == types.LegacyTxType || target == types.AccessListTxType
+	switch {
+	case fromPrice && !toPrice:
+		out.GasPrice = nil
+		out.GasFeeCap = r.GasPrice
+		out.GasTipCap = r.GasPrice
+		if baseFee != nil && r.GasPrice != nil {
+			out.GasTipCap = new(big.Int).Sub(r.GasPrice, baseFee)
+			if out.GasTipCap.Sign() < 0 {
+				out.GasTipCap.SetInt64(0)
+			}
+		}
+	case !fromPrice && toPrice:
+		out.GasTipCap, out.GasFeeCap = nil, nil
+		out.GasPrice = r.GasFeeCap
+		if baseFee != nil && r.GasFeeCap != nil && r.GasTipCap != nil {
+			if effective := new(big.Int).Add(baseFee, r.GasTipCap); effective.Cmp(r.GasFeeCap) < 0 {
+				out.GasPrice = effective
+			}
+		}
+	}
+
+	// Fields the target can't carry
+	if target == types.LegacyTxType && len(r.AccessList) > 0 {
+		losses = append(losses, Loss{"AccessList", fmt.Sprintf("%d entries, legacy transactions have no access list", len(r.AccessList))})
+		out.AccessList = nil
+	}
+	if target != types.BlobTxType {
+		if len(r.BlobHashes) > 0 {
+			losses = append(losses, Loss{"BlobHashes", fmt.Sprintf("%d blobs, only blob transactions carry blobs", len(r.BlobHashes))})
+		}
+		if r.BlobFeeCap != nil {
+			losses = append(losses, Loss{"BlobFeeCap", "only blob transactions pay blob gas"})
+		}
+		if r.Sidecar != nil {
+			losses = append(losses, Loss{"Sidecar", "only blob transactions carry blobs"})
+		}
+		out.BlobHashes, out.BlobFeeCap, out.Sidecar = nil, nil, nil
+	}
+	if target != types.SetCodeTxType {
+		if len(r.AuthList) > 0 {
+			losses = append(losses, Loss{"AuthList", fmt.Sprintf("%d authorizations, only set code transactions delegate", len(r.AuthList))})
+		}
+		out.AuthList = nil
+	} else if r.Type != types.SetCodeTxType && out.Gas > 0 {
+		// Each authorization is charged like creating an account up front
+		out.Gas += uint64(len(r.AuthList)) * params.CallNewAccountGas
+	}
+	return &out, losses, nil
+}
+
+// Additions are the fields a conversion target needs that the source
+// transaction doesn't have.
+type Additions struct {
+	// AuthList is appended to the source's authorizations
+	AuthList []types.SetCodeAuthorization
+	// Sidecar replaces the source's blobs, BlobHashes are taken from it
+	Sidecar    *types.BlobTxSidecar
+	BlobFeeCap *big.Int
+}
+
+// ConvertTx converts a signed transaction into an unsigned one of type target,
+// see Request.Convert. add supplies the authorizations or blobs the source
+// lacks.
+func ConvertTx(tx *types.Transaction, from common.Address, target uint8, baseFee *big.Int, add Additions) (*types.Transaction, []Loss, error) {
+	r := RequestFromTx(tx, from)
+	if len(add.AuthList) > 0 {
+		r.AuthList = append(slices.Clone(r.AuthList), add.AuthList...)
+	}
+	if add.Sidecar != nil {
+		r.Sidecar, r.BlobHashes = add.Sidecar, add.Sidecar.BlobHashes()
+	}
+	if add.BlobFeeCap != nil {
+		r.BlobFeeCap = add.BlobFeeCap
+	}
+	r, losses, err := r.Convert(target, baseFee)
+	if err != nil {
+		return nil, nil, err
+	}
+	converted, err := r.Build()
+	if err != nil {
+		return nil, nil, err
+	}
+	return converted, losses, nil
+}
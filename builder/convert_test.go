@@ -0,0 +1,82 @@
+package builder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestConvertDynamicFeeToSetCode(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	chainID := big.NewInt(1)
+
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     7,
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: big.NewInt(30 * params.GWei),
+		Gas:       50_000,
+		To:        &to,
+		Data:      []byte{0xde, 0xad, 0xbe, 0xef},
+		AccessList: types.AccessList{{
+			Address:     to,
+			StorageKeys: []common.Hash{{1}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := types.SignSetCode(key, types.SetCodeAuthorization{
+		ChainID: *uint256.NewInt(1),
+		Address: common.HexToAddress("0x00000000000000000000000000000000000000c1"),
+		Nonce:   8,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ConvertTx(tx, from, types.SetCodeTxType, nil, Additions{}); err == nil {
+		t.Fatal("converted to a set code transaction without authorizations")
+	}
+	converted, losses, err := ConvertTx(tx, from, types.SetCodeTxType, nil, Additions{AuthList: []types.SetCodeAuthorization{auth}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(losses) != 0 {
+		t.Errorf("lost %v", losses)
+	}
+	if converted.Type() != types.SetCodeTxType {
+		t.Fatalf("type %d, want %d", converted.Type(), types.SetCodeTxType)
+	}
+	if auths := converted.SetCodeAuthorizations(); len(auths) != 1 || auths[0] != auth {
+		t.Errorf("authorizations %v, want %v", auths, auth)
+	}
+	if authority, err := auth.Authority(); err != nil || authority != from {
+		t.Errorf("authority %s (%v), want %s", authority.Hex(), err, from.Hex())
+	}
+	if want := tx.Gas() + params.CallNewAccountGas; converted.Gas() != want {
+		t.Errorf("gas %d, want %d", converted.Gas(), want)
+	}
+	if converted.GasTipCap().Cmp(tx.GasTipCap()) != 0 || converted.GasFeeCap().Cmp(tx.GasFeeCap()) != 0 {
+		t.Errorf("fees %v/%v, want %v/%v", converted.GasTipCap(), converted.GasFeeCap(), tx.GasTipCap(), tx.GasFeeCap())
+	}
+	if converted.Nonce() != tx.Nonce() || *converted.To() != to || string(converted.Data()) != string(tx.Data()) || len(converted.AccessList()) != 1 {
+		t.Errorf("intent not carried over: %+v", converted)
+	}
+
+	// Back to 1559, the authorizations are reported as lost
+	back, losses, err := ConvertTx(converted, from, types.DynamicFeeTxType, nil, Additions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(losses) != 1 || losses[0].Field != "AuthList" || len(back.SetCodeAuthorizations()) != 0 {
+		t.Errorf("losses %v", losses)
+	}
+}
@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ErrCreationNotAllowed is returned for contract creations in transaction
+// types that require a recipient: blob (EIP-4844) and set code (EIP-7702).
+var ErrCreationNotAllowed = errors.New("transaction type cannot create contracts")
+
+// IsCreate reports whether the request deploys a contract.
+func (r *Request) IsCreate() bool {
+	return r.To == nil
+}
+
+// ContractAddress returns the address the contract will be deployed at:
+// keccak256(rlp([sender, nonce]))[12:]. It only holds if the transaction is
+// included with r.Nonce.
+func (r *Request) ContractAddress() (common.Address, error) {
+	if !r.IsCreate() {
+		return common.Address{}, errors.New("not a contract creation")
+	}
+	switch r.Type {
+	case types.BlobTxType, types.SetCodeTxType:
+		return common.Address{}, fmt.Errorf("%w (type %d)", ErrCreationNotAllowed, r.Type)
+	}
+	return crypto.CreateAddress(r.From, r.Nonce), nil
+}
+
+// VerifyDeployment checks that a creation receipt deployed code at expected.
+// If runtime is given, the deployed code has to match it exactly. It returns
+// the deployed code.
+func VerifyDeployment(ctx context.Context, client *ethclient.Client, receipt *types.Receipt, expected common.Address, runtime []byte) ([]byte, error) {
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("deployment %s reverted", receipt.TxHash.Hex())
+	}
+	if receipt.ContractAddress != expected {
+		return nil, fmt.Errorf("contract deployed at %s, expected %s", receipt.ContractAddress.Hex(), expected.Hex())
+	}
+	return VerifyCode(ctx, client, expected, receipt.BlockNumber, runtime)
+}
+
+// VerifyCode checks that addr has code at the given block (nil for latest) and,
+// if runtime is given, that it matches exactly. It returns the code.
+func VerifyCode(ctx context.Context, client *ethclient.Client, addr common.Address, block *big.Int, runtime []byte) ([]byte, error) {
+	code, err := client.CodeAt(ctx, addr, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch code: %w", err)
+	}
+	// Init code that returns nothing succeeds but leaves an empty account
+	if len(code) == 0 {
+		return nil, fmt.Errorf("no code at %s after deployment", addr.Hex())
+	}
+	if runtime != nil && !bytes.Equal(code, runtime) {
+		return code, fmt.Errorf("deployed code at %s differs from the expected runtime code", addr.Hex())
+	}
+	return code, nil
+}
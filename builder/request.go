@@ -0,0 +1,186 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Request describes a transaction independently of its envelope. Build turns
+// it into the typed transaction selected by Type.
+type Request struct {
+	Type    uint8 // types.LegacyTxType, AccessListTxType, DynamicFeeTxType, BlobTxType or SetCodeTxType
+	ChainID *big.Int
+	From    common.Address
+	To      *common.Address // nil deploys Data as init code
+	Nonce   uint64
+	Value   *big.Int
+	Data    []byte
+	Gas     uint64
+
+	GasPrice  *big.Int // legacy and access list transactions
+	GasTipCap *big.Int // dynamic fee, blob and set code transactions
+	GasFeeCap *big.Int
+
+	// AccessList is accepted by every type except legacy transactions. Use
+	// SetAccessList or GenerateAccessList to have it validated.
+	AccessList types.AccessList
+
+	// Blob transactions only
+	BlobFeeCap *big.Int
+	BlobHashes []common.Hash
+	Sidecar    *types.BlobTxSidecar
+
+	// Set code transactions only
+	AuthList []types.SetCodeAuthorization
+}
+
+// CallMsg returns the request as a call, for gas estimation and access list
+// generation.
+func (r *Request) CallMsg() ethereum.CallMsg {
+	msg := ethereum.CallMsg{
+		From:       r.From,
+		To:         r.To,
+		Gas:        r.Gas,
+		Value:      r.Value,
+		Data:       r.Data,
+		AccessList: r.AccessList,
+	}
+	switch r.Type {
+	case types.LegacyTxType, types.AccessListTxType:
+		msg.GasPrice = r.GasPrice
+	default:
+		msg.GasTipCap, msg.GasFeeCap = r.GasTipCap, r.GasFeeCap
+	}
+	if r.Type == types.BlobTxType {
+		msg.BlobGasFeeCap, msg.BlobHashes = r.BlobFeeCap, r.BlobHashes
+	}
+	if r.Type == types.SetCodeTxType {
+		msg.AuthorizationList = r.AuthList
+	}
+	return msg
+}
+
+// TxData returns the typed transaction payload for the request.
+func (r *Request) TxData() (types.TxData, error) {
+	if r.Type == types.LegacyTxType && len(r.AccessList) > 0 {
+		return nil, errors.New("legacy transactions cannot carry an access list")
+	}
+	if r.IsCreate() && len(r.Data) > params.MaxInitCodeSize {
+		return nil, fmt.Errorf("init code is %d bytes, the limit is %d (EIP-3860)", len(r.Data), params.MaxInitCodeSize)
+	}
+
+	switch r.Type {
+	case types.LegacyTxType:
+		return &types.LegacyTx{
+			Nonce:    r.Nonce,
+			GasPrice: r.GasPrice,
+			Gas:      r.Gas,
+			To:       r.To,
+			Value:    r.Value,
+			Data:     r.Data,
+		}, nil
+
+	case types.AccessListTxType:
+		return &types.AccessListTx{
+			ChainID:    r.ChainID,
+			Nonce:      r.Nonce,
+			GasPrice:   r.GasPrice,
+			Gas:        r.Gas,
+			To:         r.To,
+			Value:      r.Value,
+			Data:       r.Data,
+			AccessList: r.AccessList,
+		}, nil
+
+	case types.DynamicFeeTxType:
+		return &types.DynamicFeeTx{
+			ChainID:    r.ChainID,
+			Nonce:      r.Nonce,
+			GasTipCap:  r.GasTipCap,
+			GasFeeCap:  r.GasFeeCap,
+			Gas:        r.Gas,
+			To:         r.To,
+			Value:      r.Value,
+			Data:       r.Data,
+			AccessList: r.AccessList,
+		}, nil
+
+	case types.BlobTxType:
+		if r.To == nil {
+			return nil, fmt.Errorf("%w: blob transactions need a recipient", ErrCreationNotAllowed)
+		}
+		fees, err := toUint256(r.ChainID, r.GasTipCap, r.GasFeeCap, r.Value, r.BlobFeeCap)
+		if err != nil {
+			return nil, err
+		}
+		return &types.BlobTx{
+			ChainID:    fees[0],
+			Nonce:      r.Nonce,
+			GasTipCap:  fees[1],
+			GasFeeCap:  fees[2],
+			Gas:        r.Gas,
+			To:         *r.To,
+			Value:      fees[3],
+			Data:       r.Data,
+			AccessList: r.AccessList,
+			BlobFeeCap: fees[4],
+			BlobHashes: r.BlobHashes,
+			Sidecar:    r.Sidecar,
+		}, nil
+
+	case types.SetCodeTxType:
+		if r.To == nil {
+			return nil, fmt.Errorf("%w: set code transactions need a recipient", ErrCreationNotAllowed)
+		}
+		fees, err := toUint256(r.ChainID, r.GasTipCap, r.GasFeeCap, r.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &types.SetCodeTx{
+			ChainID:    fees[0],
+			Nonce:      r.Nonce,
+			GasTipCap:  fees[1],
+			GasFeeCap:  fees[2],
+			Gas:        r.Gas,
+			To:         *r.To,
+			Value:      fees[3],
+			Data:       r.Data,
+			AccessList: r.AccessList,
+			AuthList:   r.AuthList,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", r.Type)
+	}
+}
+
+// Build returns the unsigned transaction for the request.
+func (r *Request) Build() (*types.Transaction, error) {
+	data, err := r.TxData()
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTx(data), nil
+}
+
+// toUint256 converts the given values, treating nil as zero.
+func toUint256(values ...*big.Int) ([]*uint256.Int, error) {
+	out := make([]*uint256.Int, len(values))
+	for i, v := range values {
+		out[i] = new(uint256.Int)
+		if v == nil {
+			continue
+		}
+		if overflow := out[i].SetFromBig(v); overflow || v.Sign() < 0 {
+			return nil, fmt.Errorf("value %s does not fit into 256 bits", v)
+		}
+	}
+	return out, nil
+}
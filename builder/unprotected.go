@@ -0,0 +1,128 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrUnprotectedRejected is returned when the node refuses transactions
+// without chain ID (geth without --rpc.allow-unprotected-txs).
+var ErrUnprotectedRejected = errors.New("node only accepts replay-protected (EIP-155) transactions")
+
+// ErrProbeUnderpriced is returned when the unprotected probe was refused for
+// its gas price, before the node looked at replay protection or funds. The
+// probe can be retried.
+var ErrProbeUnderpriced = errors.New("could not determine whether the node accepts unprotected transactions, the probe was underpriced; retry")
+
+// Signer returns the signer for the request. Unprotected signing leaves the
+// chain ID out of the signature (Homestead), so the signed transaction is
+// valid on every chain where the sender has the nonce and funds. It is only
+// possible for legacy transactions.
+func (r *Request) Signer(unprotected bool) (types.Signer, error) {
+	if !unprotected {
+		return types.LatestSignerForChainID(r.ChainID), nil
+	}
+	if r.Type != types.LegacyTxType {
+		return nil, fmt.Errorf("only legacy transactions can be signed without chain ID, not type %d", r.Type)
+	}
+	return types.HomesteadSigner{}, nil
+}
+
+// SignerOf returns the signer a signed transaction was signed with:
+// Homestead for unprotected legacy transactions, the latest for its chain
+// otherwise.
+func SignerOf(tx *types.Transaction) types.Signer {
+	if !tx.Protected() {
+		return types.HomesteadSigner{}
+	}
+	return types.LatestSignerForChainID(tx.ChainId())
+}
+
+// Sender recovers the sender of a signed transaction, with or without chain
+// ID.
+func Sender(tx *types.Transaction) (common.Address, error) {
+	return types.Sender(SignerOf(tx), tx)
+}
+
+// IsUnprotectedRejection reports whether err is a node refusing a transaction
+// because it lacks EIP-155 replay protection.
+func IsUnprotectedRejection(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrUnprotectedRejected) {
+		return true
+	}
+	// geth: "only replay-protected (EIP-155) transactions allowed over RPC"
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "replay-protected") || strings.Contains(msg, "replay protected")
+}
+
+// CheckUnprotectedAllowed probes whether the node accepts unprotected
+// transactions over RPC. It submits an unprotected transfer from a fresh key
+// with no funds: nodes that accept unprotected transactions fail it in the
+// pool for insufficient funds, nodes that don't refuse it up front. Either
+// way nothing is broadcast. The probe is priced at the suggested gas price,
+// since the pool checks the minimum tip before the balance.
+func CheckUnprotectedAllowed(ctx context.Context, client *ethclient.Client) error {
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to price the unprotected probe: %w", err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	to := crypto.PubkeyToAddress(key.PublicKey)
+	probe, err := types.SignTx(types.NewTx(&types.LegacyTx{
+		GasPrice: gasPrice,
+		Gas:      params.TxGas,
+		To:       &to,
+	}), types.HomesteadSigner{}, key)
+	if err != nil {
+		return err
+	}
+
+	return probeResult(client.SendTransaction(ctx, probe))
+}
+
+// probeResult interprets the node's answer to the unprotected probe.
+func probeResult(err error) error {
+	switch {
+	case err == nil:
+		// Should not happen without funds, but the node clearly accepted it
+		return nil
+	case IsUnprotectedRejection(err):
+		return fmt.Errorf("%w: %v", ErrUnprotectedRejected, err)
+	case isInsufficientFunds(err):
+		// The replay protection check comes first, a probe that got as far as
+		// the balance check was accepted as unprotected
+		return nil
+	case isUnderpriced(err):
+		// Refused before the balance check, the suggested price moved
+		return fmt.Errorf("%w: %v", ErrProbeUnderpriced, err)
+	default:
+		return fmt.Errorf("could not determine whether the node accepts unprotected transactions: %w", err)
+	}
+}
+
+// isInsufficientFunds reports whether err is the pool refusing a transaction
+// the sender cannot pay for. The error type is lost over RPC.
+func isInsufficientFunds(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "insufficient funds")
+}
+
+// isUnderpriced reports whether err is the pool refusing a transaction below
+// its minimum tip or gas price.
+func isUnderpriced(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "underpriced") || strings.Contains(msg, "below minimum")
+}
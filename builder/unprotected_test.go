@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestCheckUnprotectedAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		unprotected bool   // --rpc.allow-unprotected-txs
+		minTip      uint64 // --txpool.pricelimit, above the suggested gas price
+		want        error
+	}{
+		{"protected only", false, 0, ErrUnprotectedRejected},
+		{"unprotected allowed", true, 0, nil},
+		{"minimum tip", true, 1000 * params.GWei, ErrProbeUnderpriced},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipc := filepath.Join(t.TempDir(), "sim.ipc")
+			sim := simulated.NewBackend(types.GenesisAlloc{}, func(nodeConf *node.Config, ethConf *ethconfig.Config) {
+				nodeConf.IPCPath = ipc
+				nodeConf.AllowUnprotectedTxs = tt.unprotected
+				if tt.minTip > 0 {
+					ethConf.TxPool.PriceLimit = tt.minTip
+				}
+			})
+			defer sim.Close()
+			client, err := ethclient.Dial(ipc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			err = CheckUnprotectedAllowed(context.Background(), client)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
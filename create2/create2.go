@@ -0,0 +1,196 @@
+// Package create2 deploys contracts to the same address on every chain through
+// the deterministic deployment proxy
+// (https://github.com/Arachnid/deterministic-deployment-proxy).
+//
+// The proxy is deployed by a pre-signed transaction without chain ID
+// (pre-EIP-155), so the same bytes are valid on any chain and the proxy always
+// lands at the same address. Calling it with salt ++ init code runs CREATE2,
+// whose result depends only on the proxy address, the salt and the init code.
+package create2
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"transactiontypes/builder"
+	"transactiontypes/journal"
+	"transactiontypes/policy"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// factoryDeploymentTx is the proxy's pre-signed deployment: nonce 0, 100 gwei
+// gas price, 100000 gas, signed with r = s = 0x22..22 so nobody knows the key.
+const factoryDeploymentTx = "0xf8a58085174876e800830186a08080b853604580600e600039806000f350fe7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe03601600081602082378035828234f58015156039578182fd5b8082525050506014600cf31ba02222222222222222222222222222222222222222222222222222222222222222a02222222222222222222222222222222222222222222222222222222222222222"
+
+var (
+	// FactoryAddress is where the proxy lives on every chain it is deployed on.
+	FactoryAddress = common.HexToAddress("0x4e59b44847b379578588920ca78fbf26c0b4956c")
+	// FactoryDeployer is the one-time sender recovered from the pre-signed
+	// deployment. It has to be funded with FactoryDeploymentCost.
+	FactoryDeployer = common.HexToAddress("0x3fab184622dc19b6109349b94811493bf2a45362")
+)
+
+// FactoryDeploymentTx returns the pre-signed proxy deployment.
+func FactoryDeploymentTx() (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(common.FromHex(factoryDeploymentTx)); err != nil {
+		return nil, err
+	}
+	// There is no chain ID to sign over, the sender recovers with the Homestead
+	// signer
+	from, err := builder.Sender(tx)
+	if err != nil {
+		return nil, err
+	}
+	if from != FactoryDeployer || crypto.CreateAddress(from, tx.Nonce()) != FactoryAddress {
+		return nil, errors.New("factory deployment transaction does not match the factory address")
+	}
+	return tx, nil
+}
+
+// FactoryDeploymentCost is the balance FactoryDeployer needs: 0.01 ETH.
+func FactoryDeploymentCost() *big.Int {
+	return new(big.Int).Mul(big.NewInt(100000), big.NewInt(100*params.GWei))
+}
+
+// Address returns the address init code deploys to with salt:
+// keccak256(0xff ++ factory ++ salt ++ keccak256(initCode))[12:].
+func Address(salt common.Hash, initCode []byte) common.Address {
+	return crypto.CreateAddress2(FactoryAddress, salt, crypto.Keccak256(initCode))
+}
+
+// CallData returns the proxy input deploying initCode with salt. The proxy
+// has no ABI, it takes the salt followed by the raw init code.
+func CallData(salt common.Hash, initCode []byte) []byte {
+	return append(salt.Bytes(), initCode...)
+}
+
+// Deployed reports whether addr has code.
+func Deployed(ctx context.Context, client *ethclient.Client, addr common.Address) (bool, error) {
+	code, err := client.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch code: %w", err)
+	}
+	return len(code) > 0, nil
+}
+
+// EnsureFactory deploys the proxy if the chain doesn't have it yet, topping up
+// FactoryDeployer from funder first. The top-up is held to the spending
+// policy, and both transactions are journaled under network, the node's RPC
+// URL. It waits for inclusion of both.
+func EnsureFactory(ctx context.Context, client *ethclient.Client, network string, chainID *big.Int, funder *ecdsa.PrivateKey) error {
+	if ok, err := Deployed(ctx, client, FactoryAddress); err != nil || ok {
+		return err
+	}
+	deployTx, err := FactoryDeploymentTx()
+	if err != nil {
+		return err
+	}
+
+	nonce, err := client.NonceAt(ctx, FactoryDeployer, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch deployer nonce: %w", err)
+	}
+	if nonce != 0 {
+		// The deployer key is unknown, a used nonce 0 can never be repaired
+		return fmt.Errorf("deployer %s already used nonce 0, the factory cannot be deployed on this chain", FactoryDeployer.Hex())
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch latest header: %w", err)
+	}
+	if head.BaseFee != nil && head.BaseFee.Cmp(deployTx.GasPrice()) > 0 {
+		return fmt.Errorf("base fee %s is above the fixed factory deployment gas price %s", head.BaseFee, deployTx.GasPrice())
+	}
+
+	// Find out before spending anything on funding the deployer
+	if err := builder.CheckUnprotectedAllowed(ctx, client); err != nil {
+		return fmt.Errorf("cannot broadcast the pre-EIP-155 factory deployment, try another RPC: %w", err)
+	}
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	balance, err := client.BalanceAt(ctx, FactoryDeployer, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch deployer balance: %w", err)
+	}
+	if missing := new(big.Int).Sub(FactoryDeploymentCost(), balance); missing.Sign() > 0 {
+		if err := fund(ctx, client, jrnl, network, chainID, funder, missing); err != nil {
+			return fmt.Errorf("failed to fund factory deployer: %w", err)
+		}
+	}
+
+	if err := jrnl.Broadcast(ctx, client, network, deployTx, FactoryDeployer); err != nil {
+		return fmt.Errorf("failed to send factory deployment: %w", err)
+	}
+	receipt, err := bind.WaitMined(ctx, client, deployTx.Hash())
+	if err != nil {
+		return fmt.Errorf("factory deployment %s: %w", deployTx.Hash().Hex(), err)
+	}
+	_, err = builder.VerifyDeployment(ctx, client, receipt, FactoryAddress, nil)
+	return err
+}
+
+// fund sends amount to FactoryDeployer and waits for inclusion.
+func fund(ctx context.Context, client *ethclient.Client, jrnl *journal.Journal, network string, chainID *big.Int, funder *ecdsa.PrivateKey, amount *big.Int) error {
+	from := crypto.PubkeyToAddress(funder.PublicKey)
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return err
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return err
+	}
+	// A plain transfer, legacy pricing works on chains with and without EIP-1559
+	req := &builder.Request{
+		Type:     types.LegacyTxType,
+		ChainID:  chainID,
+		From:     from,
+		To:       &FactoryDeployer,
+		Nonce:    nonce,
+		Value:    amount,
+		Gas:      params.TxGas,
+		GasPrice: gasPrice,
+	}
+	spending, err := policy.Default()
+	if err != nil {
+		return fmt.Errorf("failed to load spending policy: %w", err)
+	}
+	if err := spending.Check(req); err != nil {
+		return err
+	}
+	tx, err := req.Build()
+	if err != nil {
+		return err
+	}
+	signer := types.LatestSignerForChainID(chainID)
+	if err := jrnl.RecordBuilt(tx, from, signer); err != nil {
+		return err
+	}
+	signed, err := types.SignTx(tx, signer, funder)
+	if err != nil {
+		return err
+	}
+	if err := jrnl.Broadcast(ctx, client, network, signed, from); err != nil {
+		return err
+	}
+	receipt, err := bind.WaitMined(ctx, client, signed.Hash())
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("funding transaction %s failed", signed.Hash().Hex())
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+	"transactiontypes/account"
+	"transactiontypes/builder"
+	"transactiontypes/create2"
+	"transactiontypes/journal"
+	"transactiontypes/monitor"
+	"transactiontypes/policy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	// Public RPC URL for Polygon Amoy Testnet
+	NodeRPCURL  = "https://polygon-amoy.drpc.org"
+	AmoyChainID = 80002 // Polygon Amoy Testnet Chain ID
+
+	// A contract whose runtime code returns 42 for any call:
+	// PUSH1 42 PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	RuntimeCode = "0x602a60005260206000f3"
+	// Init code copying the 10 byte runtime code behind it to memory and
+	// returning it: PUSH1 10 PUSH1 12 PUSH1 0 CODECOPY PUSH1 10 PUSH1 0 RETURN
+	InitCode = "0x600a600c600039600a6000f3" + "602a60005260206000f3"
+)
+
+var txTypes = map[string]uint8{
+	"legacy":  types.LegacyTxType,
+	"2930":    types.AccessListTxType,
+	"1559":    types.DynamicFeeTxType,
+	"blob":    types.BlobTxType,
+	"setcode": types.SetCodeTxType,
+}
+
+func main() {
+	typeName := flag.String("type", "1559", "transaction type: legacy, 2930, 1559 (blob and setcode cannot deploy)")
+	initCode := flag.String("code", InitCode, "init code to deploy")
+	runtime := flag.String("runtime", RuntimeCode, "expected runtime code, empty to skip the comparison")
+	accNum := flag.Int("account", 2, "account number to deploy from")
+	saltHex := flag.String("salt", "", "deploy through the CREATE2 factory with this 32 byte salt, for the same address on every chain")
+	flag.Parse()
+
+	txType, ok := txTypes[*typeName]
+	if !ok {
+		log.Fatalf("Unknown transaction type %q", *typeName)
+	}
+
+	ctx := context.Background()
+	client, err := ethclient.Dial(NodeRPCURL)
+	if err != nil {
+		log.Fatal("Failed to connect to Ethereum node:", err)
+	}
+
+	fromAddr, priv := account.GetAccount(*accNum)
+	nonce, err := client.PendingNonceAt(ctx, *fromAddr)
+	if err != nil {
+		log.Fatal("Failed to fetch nonce:", err)
+	}
+
+	chainID := big.NewInt(AmoyChainID)
+	// No To: the data is executed as init code and its output becomes the code
+	// of a new account
+	req := &builder.Request{
+		Type:    txType,
+		ChainID: chainID,
+		From:    *fromAddr,
+		Nonce:   nonce,
+		Data:    common.FromHex(*initCode),
+	}
+
+	// The CREATE address only depends on sender and nonce, so it is known
+	// before sending
+	contractAddr, err := req.ContractAddress()
+	if errors.Is(err, builder.ErrCreationNotAllowed) {
+		log.Fatalf("Type %s transactions cannot deploy contracts, use legacy, 2930 or 1559", *typeName)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *saltHex != "" {
+		salt, err := parseSalt(*saltHex)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// CREATE2 addresses only depend on factory, salt and init code, so
+		// they are the same on every chain and for every sender
+		contractAddr = create2.Address(salt, req.Data)
+		deployed, err := create2.Deployed(ctx, client, contractAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if deployed {
+			fmt.Println("Already deployed at", contractAddr.Hex())
+			return
+		}
+		if err := create2.EnsureFactory(ctx, client, NodeRPCURL, chainID, priv); err != nil {
+			log.Fatal("Failed to deploy CREATE2 factory:", err)
+		}
+		// Funding the factory deployer may have used a nonce
+		if req.Nonce, err = client.PendingNonceAt(ctx, *fromAddr); err != nil {
+			log.Fatal("Failed to fetch nonce:", err)
+		}
+		req.To, req.Data = &create2.FactoryAddress, create2.CallData(salt, req.Data)
+	}
+	fmt.Println("Expected contract address:", contractAddr.Hex())
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		log.Fatal("Failed to fetch latest header:", err)
+	}
+	switch txType {
+	case types.LegacyTxType, types.AccessListTxType:
+		if req.GasPrice, err = client.SuggestGasPrice(ctx); err != nil {
+			log.Fatal("Failed to fetch gas price:", err)
+		}
+	default:
+		if req.GasTipCap, err = client.SuggestGasTipCap(ctx); err != nil {
+			log.Fatal("Failed to fetch gas tip cap:", err)
+		}
+		// GasFeeCap = 2 * baseFee + tip
+		req.GasFeeCap = new(big.Int).Mul(head.BaseFee, big.NewInt(2))
+		req.GasFeeCap.Add(req.GasFeeCap, req.GasTipCap)
+	}
+
+	if txType == types.LegacyTxType {
+		if req.Gas, err = client.EstimateGas(ctx, req.CallMsg()); err != nil {
+			log.Fatal("Failed to estimate gas:", err)
+		}
+	} else {
+		alResult, err := req.GenerateAccessList(ctx, client, builder.EnvAt(nil, head))
+		if err != nil {
+			log.Fatal("Failed to create access list:", err)
+		}
+		fmt.Println(alResult)
+	}
+
+	spending, err := policy.Default()
+	if err != nil {
+		log.Fatal("Failed to load spending policy:", err)
+	}
+	if err := spending.Check(req); err != nil {
+		log.Fatal(err)
+	}
+	tx, err := req.Build()
+	if err != nil {
+		log.Fatal("Failed to build transaction:", err)
+	}
+
+	signer := types.LatestSignerForChainID(chainID)
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	if err := jrnl.RecordBuilt(tx, *fromAddr, signer); err != nil {
+		log.Fatal("Failed to journal transaction:", err)
+	}
+	signedTx, err := types.SignTx(tx, signer, priv)
+	if err != nil {
+		log.Fatal("Failed to sign transaction:", err)
+	}
+
+	err = jrnl.Broadcast(ctx, client, NodeRPCURL, signedTx, *fromAddr)
+	if err != nil {
+		log.Fatal("Broadcast failed:", err)
+	}
+	fmt.Println("Deployment sent!")
+	fmt.Println("Tx hash:", signedTx.Hash().Hex())
+
+	// Optionally wait for inclusion
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	mined, err := monitor.Wait(waitCtx, client, signedTx, *fromAddr, monitor.StatusIncluded)
+	if err != nil {
+		fmt.Println("Tx not mined yet:", err)
+		return
+	}
+	receipt := mined.Receipt
+	fmt.Println("Tx mined in block:", receipt.BlockNumber)
+
+	var want []byte
+	if *runtime != "" {
+		want = common.FromHex(*runtime)
+	}
+	var code []byte
+	if *saltHex != "" {
+		// The factory call creates the contract internally, the receipt has
+		// no contract address
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			log.Fatal("Deployment reverted, the init code failed")
+		}
+		code, err = builder.VerifyCode(ctx, client, contractAddr, receipt.BlockNumber, want)
+	} else {
+		code, err = builder.VerifyDeployment(ctx, client, receipt, contractAddr, want)
+	}
+	if err != nil {
+		log.Fatal("Deployment check failed:", err)
+	}
+	fmt.Printf("Deployed %d bytes of code at %s\n", len(code), contractAddr.Hex())
+}
+
+// parseSalt accepts up to 32 bytes of hex, left-padded with zeros.
+func parseSalt(s string) (common.Hash, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid salt %q: %w", s, err)
+	}
+	if len(b) > common.HashLength {
+		return common.Hash{}, fmt.Errorf("salt is %d bytes, at most 32 allowed", len(b))
+	}
+	return common.BytesToHash(b), nil
+}
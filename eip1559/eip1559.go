@@ -7,8 +7,12 @@ import (
 	"math/big"
 	"time"
 	"transactiontypes/account"
+	"transactiontypes/builder"
+	"transactiontypes/erc20"
+	"transactiontypes/journal"
+	"transactiontypes/monitor"
+	"transactiontypes/policy"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -18,7 +22,8 @@ import (
 const (
 	// Public RPC URL for Polygon Amoy Testnet
 	NodeRPCURL  = "https://polygon-amoy.drpc.org"
-	AmoyChainID = 80002 // Polygon Amoy Testnet Chain ID
+	TokenAmount = 0.1e18 // 0.1 tokens with 18 decimals
+	AmoyChainID = 80002  // Polygon Amoy Testnet Chain ID
 )
 
 func main() {
@@ -58,37 +63,69 @@ func main() {
 	// Final GasFeeCap = bufferedBaseFee + GasTipCap
 	GasFeeCap := new(big.Int).Add(bufferedBaseFee, GasTipCap)
 
-	chainID := big.NewInt(AmoyChainID) // Use your chain's ID (80002 = Polygon Mumbai Testnet)
-
-	gasLimit, err := client.EstimateGas(ctx, ethereum.CallMsg{
-		From: *acc2Addr,
-		To:   to,
-		Data: common.FromHex("0xa9059cbb0000000000000000000000008056361b1c1361436D61D187d761233b42d1c20e000000000000000000000000000000000000000000000000016345785D8A0000"),
-	})
+	// transfer(0x8056…c20e, 0.1 tokens) on the ERC-20 at to, see the token
+	// command for decimals and balance aware transfers
+	data, err := erc20.PackTransfer(common.HexToAddress("0x8056361b1c1361436D61D187d761233b42d1c20e"), big.NewInt(TokenAmount))
 	if err != nil {
-		log.Fatal("Failed to fetch gas limit:", err)
+		log.Fatal("Failed to encode transfer:", err)
 	}
 
-	tx := types.DynamicFeeTx{
+	chainID := big.NewInt(AmoyChainID) // Use your chain's ID (80002 = Polygon Mumbai Testnet)
+
+	// An access list is optional for 1559 transactions, attach one only if it saves gas
+	req := &builder.Request{
+		Type:      types.DynamicFeeTxType,
 		ChainID:   chainID,
+		From:      *acc2Addr,
+		To:        to,
 		Nonce:     nonce,
 		GasTipCap: GasTipCap,
 		GasFeeCap: GasFeeCap,
-		Gas:       gasLimit,
-		To:        to,
-		Data:      common.FromHex("0xa9059cbb0000000000000000000000008056361b1c1361436D61D187d761233b42d1c20e000000000000000000000000000000000000000000000000016345785D8A0000"),
+		Data:      data,
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		log.Fatal("Failed to fetch latest header:", err)
+	}
+	// Amoy has no go-ethereum chain config, assume the latest forks
+	alResult, err := req.GenerateAccessList(ctx, client, builder.EnvAt(nil, head))
+	if err != nil {
+		log.Fatal("Failed to create access list:", err)
 	}
+	fmt.Println(alResult)
 
-	// Convert it into a full types.Transaction object
-	eip1559Tx := types.NewTx(&tx)
+	// The spending policy has the last word, before anything is built or signed
+	spending, err := policy.Default()
+	if err != nil {
+		log.Fatal("Failed to load spending policy:", err)
+	}
+	if err := spending.Check(req); err != nil {
+		log.Fatal(err)
+	}
+
+	eip1559Tx, err := req.Build()
+	if err != nil {
+		log.Fatal("Failed to build transaction:", err)
+	}
+
+	// Journaled before signing, so a transaction that is never signed or sent
+	// still shows up in history
+	signer := types.LatestSignerForChainID(chainID)
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	if err := jrnl.RecordBuilt(eip1559Tx, *acc2Addr, signer); err != nil {
+		log.Fatal("Failed to journal transaction:", err)
+	}
 
-	signedTx, err := types.SignTx(eip1559Tx, types.LatestSignerForChainID(chainID), acc2Priv)
+	signedTx, err := types.SignTx(eip1559Tx, signer, acc2Priv)
 	if err != nil {
 		log.Fatal("Failed to sign transaction:", err)
 	}
 
 	// Broadcast the transaction
-	err = client.SendTransaction(ctx, signedTx)
+	err = jrnl.Broadcast(ctx, client, NodeRPCURL, signedTx, *acc2Addr)
 	if err != nil {
 		log.Fatal("Broadcast failed:", err)
 	}
@@ -97,11 +134,12 @@ func main() {
 	fmt.Println("Tx hash:", signedTx.Hash().Hex())
 
 	// Optionally wait for inclusion
-	time.Sleep(10 * time.Second)
-	receipt, err := client.TransactionReceipt(ctx, signedTx.Hash())
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	mined, err := monitor.Wait(waitCtx, client, signedTx, *acc2Addr, monitor.StatusIncluded)
 	if err != nil {
-		fmt.Println("Tx not mined yet.")
+		fmt.Println("Tx not mined yet:", err)
 	} else {
-		fmt.Println("Tx mined in block:", receipt.BlockNumber)
+		fmt.Println("Tx mined in block:", mined.BlockNumber)
 	}
 }
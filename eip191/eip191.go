@@ -0,0 +1,41 @@
+package eip191
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EIP-191 version bytes, the byte that follows the 0x19 prefix.
+const (
+	VersionIntendedValidator byte = 0x00 // <validator address><data>
+	VersionStructuredData    byte = 0x01 // EIP-712
+	VersionPersonalSign      byte = 0x45 // "Ethereum Signed Message:\n" <len><message>
+)
+
+// PersonalSignHash returns the version 0x45 hash of message, as produced by
+// personal_sign and eth_sign in wallets.
+func PersonalSignHash(message []byte) common.Hash {
+	prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	return crypto.Keccak256Hash([]byte(prefixed))
+}
+
+// IntendedValidatorHash returns the version 0x00 hash of data, bound to the
+// contract that is meant to validate the signature.
+func IntendedValidatorHash(validator common.Address, data []byte) common.Hash {
+	return crypto.Keccak256Hash([]byte{0x19, VersionIntendedValidator}, validator.Bytes(), data)
+}
+
+// Hash returns the EIP-191 hash of message for the given version. The
+// validator is only used by version 0x00.
+func Hash(version byte, validator common.Address, message []byte) (common.Hash, error) {
+	switch version {
+	case VersionPersonalSign:
+		return PersonalSignHash(message), nil
+	case VersionIntendedValidator:
+		return IntendedValidatorHash(validator, message), nil
+	default:
+		return common.Hash{}, fmt.Errorf("unsupported EIP-191 version 0x%02x", version)
+	}
+}
@@ -7,8 +7,12 @@ import (
 	"math/big"
 	"time"
 	"transactiontypes/account"
+	"transactiontypes/builder"
+	"transactiontypes/erc20"
+	"transactiontypes/journal"
+	"transactiontypes/monitor"
+	"transactiontypes/policy"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -17,6 +21,7 @@ import (
 
 const (
 	NodeRPCURL  = "https://polygon-amoy.drpc.org"
+	TokenAmount = 0.1e18  // 0.1 tokens with 18 decimals
 	AmoyChainID = 80002   // Polygon Amoy Testnet Chain ID
 	ValueToSend = 0.01e18 // 0.01 ETH
 )
@@ -43,45 +48,70 @@ func main() {
 		log.Fatal("Failed to fetch gas price:", err)
 	}
 
-	accessList := types.AccessList{
-		{
-			Address:     common.Address(common.HexToAddress("0x0fd9e8d3af1aaee056eb9e802c3a762a667b1904")),
-			StorageKeys: []common.Hash{common.HexToHash("0xf9a42dc9f268c1720e130da18118febc65e0ca534e035a0e39d30cf8daea5f0a"), common.HexToHash("0x0c2d31ae2b93233fa550fc5df04cd7b0b742c0821a2494f91cd79ca74a9e2e48")},
-		},
+	// ERC-20 transfer of 0.1 tokens, encoded from the ABI
+	data, err := erc20.PackTransfer(common.HexToAddress("0x8056361b1c1361436D61D187d761233b42d1c20e"), big.NewInt(TokenAmount))
+	if err != nil {
+		log.Fatal("Failed to encode transfer:", err)
+	}
+
+	chainID := big.NewInt(AmoyChainID) // Use your chain's ID (80002 = Polygon Mumbai Testnet)
+
+	// Describe the AccessListTx
+	req := &builder.Request{
+		Type:     types.AccessListTxType,
+		ChainID:  chainID,
+		From:     *acc2Addr,
+		To:       to,
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Data:     data,
 	}
 
-	gasLimit, err := client.EstimateGas(ctx, ethereum.CallMsg{
-		From:       *acc2Addr,
-		To:         to,
-		Data:       common.FromHex("0xa9059cbb0000000000000000000000008056361b1c1361436D61D187d761233b42d1c20e000000000000000000000000000000000000000000000000016345785D8A0000"),
-		AccessList: accessList,
-	})
+	// Let the node work out which addresses and slots the call touches, and only
+	// attach the list when pre-warming them is cheaper than the list itself. It
+	// is validated and stripped of entries that are warm anyway, the gas limit
+	// is the estimate with the chosen list.
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		log.Fatal("Failed to fetch latest header:", err)
+	}
+	// Amoy has no go-ethereum chain config, assume the latest forks
+	alResult, err := req.GenerateAccessList(ctx, client, builder.EnvAt(nil, head))
 	if err != nil {
-		log.Fatal("Failed to fetch gas limit:", err)
+		log.Fatal("Failed to create access list:", err)
 	}
+	fmt.Println(alResult)
 
-	chainID := big.NewInt(AmoyChainID) // Use your chain's ID (80002 = Polygon Mumbai Testnet)
+	spending, err := policy.Default()
+	if err != nil {
+		log.Fatal("Failed to load spending policy:", err)
+	}
+	if err := spending.Check(req); err != nil {
+		log.Fatal(err)
+	}
+
+	tx, err := req.Build()
+	if err != nil {
+		log.Fatal("Failed to build transaction:", err)
+	}
 
-	// Construct AccessListTx
-	txData := types.AccessListTx{
-		ChainID:    chainID,
-		Nonce:      nonce,
-		GasPrice:   gasPrice,
-		Gas:        gasLimit,
-		To:         to,
-		Data:       common.FromHex("0xa9059cbb0000000000000000000000008056361b1c1361436D61D187d761233b42d1c20e000000000000000000000000000000000000000000000000016345785D8A0000"),
-		AccessList: accessList,
+	signer := types.NewEIP2930Signer(chainID)
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	if err := jrnl.RecordBuilt(tx, *acc2Addr, signer); err != nil {
+		log.Fatal("Failed to journal transaction:", err)
 	}
-	tx := types.NewTx(&txData)
 
 	// Sign it
-	signedTx, err := types.SignTx(tx, types.NewEIP2930Signer(chainID), acc2Priv)
+	signedTx, err := types.SignTx(tx, signer, acc2Priv)
 	if err != nil {
 		log.Fatal("Failed to sign tx:", err)
 	}
 
 	// Broadcast
-	err = client.SendTransaction(ctx, signedTx)
+	err = jrnl.Broadcast(ctx, client, NodeRPCURL, signedTx, *acc2Addr)
 	if err != nil {
 		log.Fatal("Broadcast failed:", err)
 	}
@@ -90,11 +120,12 @@ func main() {
 	fmt.Println("Tx hash:", signedTx.Hash().Hex())
 
 	// Optionally wait for mining
-	time.Sleep(10 * time.Second)
-	receipt, err := client.TransactionReceipt(ctx, signedTx.Hash())
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	mined, err := monitor.Wait(waitCtx, client, signedTx, *acc2Addr, monitor.StatusIncluded)
 	if err != nil {
-		fmt.Println("Tx not mined yet.")
+		fmt.Println("Tx not mined yet:", err)
 	} else {
-		fmt.Println("Mined in block:", receipt.BlockNumber)
+		fmt.Println("Mined in block:", mined.BlockNumber)
 	}
 }
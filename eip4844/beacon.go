@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// secondsPerSlot is the beacon chain slot time on all public networks.
+const secondsPerSlot = 12
+
+// beaconClient is a minimal client for the beacon node REST API.
+type beaconClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newBeaconClient(baseURL string) *beaconClient {
+	return &beaconClient{baseURL: strings.TrimSuffix(baseURL, "/"), http: http.DefaultClient}
+}
+
+// beaconBlobSidecar is one entry of /eth/v1/beacon/blob_sidecars/{block_id}.
+type beaconBlobSidecar struct {
+	Index         string             `json:"index"`
+	Blob          kzg4844.Blob       `json:"blob"`
+	KZGCommitment kzg4844.Commitment `json:"kzg_commitment"`
+	KZGProof      kzg4844.Proof      `json:"kzg_proof"`
+}
+
+// get fetches path and decodes the "data" field of the response into out.
+func (c *beaconClient) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("GET %s: %w", path, err)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// genesisTime returns the beacon chain genesis timestamp.
+func (c *beaconClient) genesisTime(ctx context.Context) (uint64, error) {
+	var genesis struct {
+		GenesisTime string `json:"genesis_time"`
+	}
+	if err := c.get(ctx, "/eth/v1/beacon/genesis", &genesis); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(genesis.GenesisTime, 10, 64)
+}
+
+// blobSidecars returns all blob sidecars of a beacon block.
+func (c *beaconClient) blobSidecars(ctx context.Context, blockID string) ([]beaconBlobSidecar, error) {
+	var sidecars []beaconBlobSidecar
+	if err := c.get(ctx, "/eth/v1/beacon/blob_sidecars/"+blockID, &sidecars); err != nil {
+		return nil, err
+	}
+	return sidecars, nil
+}
+
+// fetchBlobs retrieves the blobs of an included blob transaction from the
+// beacon node, checks them against the transaction's versioned hashes and KZG
+// proofs, and decodes the original payload.
+func fetchBlobs(ctx context.Context, client *ethclient.Client, beacon *beaconClient, txHash common.Hash) ([]byte, error) {
+	tx, pending, err := client.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	if tx.Type() != types.BlobTxType {
+		return nil, fmt.Errorf("transaction %s is type %d, not a blob transaction", txHash.Hex(), tx.Type())
+	}
+	if pending {
+		return nil, fmt.Errorf("transaction %s is still pending", txHash.Hex())
+	}
+	receipt, err := client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch receipt: %w", err)
+	}
+	header, err := client.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch block header: %w", err)
+	}
+
+	// The beacon API is addressed by slot, derived from the execution timestamp
+	genesis, err := beacon.genesisTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch beacon genesis: %w", err)
+	}
+	if header.Time < genesis {
+		return nil, fmt.Errorf("block %d predates beacon genesis", header.Number)
+	}
+	slot := (header.Time - genesis) / secondsPerSlot
+
+	blobs, err := beacon.slotBlobs(ctx, slot, tx.BlobHashes())
+	if err != nil {
+		return nil, err
+	}
+	return decodeBlobs(blobs)
+}
+
+// slotBlobs fetches the sidecars of a slot and returns the blobs with the given
+// versioned hashes, in order, after checking their KZG proofs.
+func (c *beaconClient) slotBlobs(ctx context.Context, slot uint64, hashes []common.Hash) ([]kzg4844.Blob, error) {
+	sidecars, err := c.blobSidecars(ctx, strconv.FormatUint(slot, 10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch blob sidecars of slot %d: %w", slot, err)
+	}
+
+	// A block carries blobs of several transactions, match ours by versioned hash
+	byHash := make(map[common.Hash]*beaconBlobSidecar, len(sidecars))
+	hasher := sha256.New()
+	for i := range sidecars {
+		byHash[kzg4844.CalcBlobHashV1(hasher, &sidecars[i].KZGCommitment)] = &sidecars[i]
+	}
+
+	kzg, err := loadKZG()
+	if err != nil {
+		return nil, err
+	}
+	blobs := make([]kzg4844.Blob, len(hashes))
+	for i, hash := range hashes {
+		sidecar, ok := byHash[hash]
+		if !ok {
+			return nil, fmt.Errorf("blob %d (%s) not found in slot %d, it may have been pruned", i, hash.Hex(), slot)
+		}
+		if err := kzg.VerifyBlobProof(&sidecar.Blob, sidecar.KZGCommitment, sidecar.KZGProof); err != nil {
+			return nil, fmt.Errorf("blob %d (sidecar index %s): %w", i, sidecar.Index, err)
+		}
+		blobs[i] = sidecar.Blob
+	}
+	return blobs, nil
+}
+
+// printPayload shows the payload as text when it is printable, hex otherwise.
+func printPayload(payload []byte) {
+	fmt.Printf("Payload (%d bytes):\n", len(payload))
+	if isPrintable(payload) {
+		fmt.Println(string(payload))
+	} else {
+		fmt.Println(hexutil.Encode(payload))
+	}
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, c := range string(b) {
+		if c < 0x20 && c != '\n' && c != '\t' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
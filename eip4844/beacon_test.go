@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// sidecarFixture builds the beacon API form of the sidecars of payload,
+// starting at index first, together with the transaction's versioned hashes.
+func sidecarFixture(t *testing.T, payload []byte, first int) ([]map[string]any, []common.Hash) {
+	t.Helper()
+	sidecar, hashes, err := buildSidecar(encodeBlobs(payload), maxBlobsPerTxOsaka, blobSidecarVersion0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]any
+	for i := range sidecar.Blobs {
+		entries = append(entries, map[string]any{
+			"index":          strconv.Itoa(first + i),
+			"blob":           hexutil.Encode(sidecar.Blobs[i][:]),
+			"kzg_commitment": hexutil.Encode(sidecar.Commitments[i][:]),
+			"kzg_proof":      hexutil.Encode(sidecar.Proofs[i][:]),
+			// Served by real beacon nodes, not needed to match blobs
+			"kzg_commitment_inclusion_proof": []string{},
+		})
+	}
+	return entries, hashes
+}
+
+// newBeaconStandIn serves the given sidecars per slot the way a beacon node
+// does, and 404 for any other slot.
+func newBeaconStandIn(t *testing.T, slots map[string][]map[string]any) *beaconClient {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /eth/v1/beacon/genesis", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"genesis_time": "1695902400"}})
+	})
+	mux.HandleFunc("GET /eth/v1/beacon/blob_sidecars/{id}", func(w http.ResponseWriter, r *http.Request) {
+		sidecars, ok := slots[r.PathValue("id")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"code": 404, "message": "NOT_FOUND: beacon block at slot " + r.PathValue("id")})
+			return
+		}
+		if sidecars == nil {
+			sidecars = []map[string]any{}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": sidecars})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return newBeaconClient(srv.URL + "/")
+}
+
+func TestSlotBlobs(t *testing.T) {
+	payload := bytes.Repeat([]byte("blob payload "), 1000)
+	ours, hashes := sidecarFixture(t, payload, 0)
+	// Another transaction's blob in the same block
+	theirs, _ := sidecarFixture(t, []byte("someone else"), len(ours))
+
+	// The commitment and proof of our blob, served with another blob's data
+	swapped := make(map[string]any)
+	for k, v := range ours[0] {
+		swapped[k] = v
+	}
+	swapped["blob"] = theirs[0]["blob"]
+
+	beacon := newBeaconStandIn(t, map[string][]map[string]any{
+		"100": append(append([]map[string]any{}, theirs...), ours...),
+		"101": theirs,
+		"102": {swapped},
+		"103": nil,
+	})
+	genesis, err := beacon.genesisTime(context.Background())
+	if err != nil || genesis != 1695902400 {
+		t.Fatalf("genesisTime = %d, %v", genesis, err)
+	}
+
+	tests := []struct {
+		name    string
+		slot    uint64
+		wantErr string
+	}{
+		{"matching sidecar", 100, ""},
+		{"commitment of another transaction", 101, "not found in slot 101"},
+		{"blob does not match its commitment", 102, "sidecar index 0"},
+		{"unknown slot", 104, "404"},
+		{"slot without blobs", 103, "not found in slot 103"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs, err := beacon.slotBlobs(context.Background(), tt.slot, hashes)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := decodeBlobs(blobs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, payload) {
+				t.Fatal("decoded payload differs from what was sent")
+			}
+		})
+	}
+}
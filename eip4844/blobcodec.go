@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+)
+
+// Blob encoding
+//
+// A blob is 4096 field elements of 32 bytes each, and every element must be
+// smaller than the BLS12-381 scalar field modulus. Writing raw bytes into a
+// blob breaks as soon as a 32 byte chunk exceeds the modulus, so we only use
+// the low 31 bytes of each element and always leave the top byte zero.
+//
+// The payload is prefixed with its length as an 8 byte big endian integer,
+// and the resulting stream is spread over as many blobs as needed.
+const (
+	fieldElementsPerBlob   = 4096
+	bytesPerFieldElement   = 32
+	usableBytesPerElement  = 31
+	usableBytesPerBlob     = fieldElementsPerBlob * usableBytesPerElement
+	blobPayloadLengthBytes = 8
+)
+
+// blobsNeeded returns how many blobs encodeBlobs will produce for n bytes.
+func blobsNeeded(n int) int {
+	total := blobPayloadLengthBytes + n
+	return (total + usableBytesPerBlob - 1) / usableBytesPerBlob
+}
+
+// encodeBlobs packs data into canonical blobs, 31 bytes per field element.
+func encodeBlobs(data []byte) []kzg4844.Blob {
+	stream := make([]byte, blobPayloadLengthBytes+len(data))
+	binary.BigEndian.PutUint64(stream, uint64(len(data)))
+	copy(stream[blobPayloadLengthBytes:], data)
+
+	blobs := make([]kzg4844.Blob, blobsNeeded(len(data)))
+	for i := range blobs {
+		for fe := 0; fe < fieldElementsPerBlob && len(stream) > 0; fe++ {
+			// Byte 0 of every element stays zero to keep it below the modulus
+			offset := fe*bytesPerFieldElement + 1
+			n := copy(blobs[i][offset:offset+usableBytesPerElement], stream)
+			stream = stream[n:]
+		}
+	}
+	return blobs
+}
+
+// decodeBlobs reverses encodeBlobs and returns the original payload.
+func decodeBlobs(blobs []kzg4844.Blob) ([]byte, error) {
+	if len(blobs) == 0 {
+		return nil, errors.New("no blobs to decode")
+	}
+
+	stream := make([]byte, 0, len(blobs)*usableBytesPerBlob)
+	for i := range blobs {
+		for fe := 0; fe < fieldElementsPerBlob; fe++ {
+			offset := fe * bytesPerFieldElement
+			if blobs[i][offset] != 0 {
+				return nil, fmt.Errorf("blob %d: field element %d is not canonically encoded", i, fe)
+			}
+			stream = append(stream, blobs[i][offset+1:offset+bytesPerFieldElement]...)
+		}
+	}
+
+	length := binary.BigEndian.Uint64(stream)
+	stream = stream[blobPayloadLengthBytes:]
+	if length > uint64(len(stream)) {
+		return nil, fmt.Errorf("payload length %d exceeds blob capacity %d", length, len(stream))
+	}
+	if blobsNeeded(int(length)) != len(blobs) {
+		return nil, fmt.Errorf("payload of %d bytes should span %d blobs, got %d", length, blobsNeeded(int(length)), len(blobs))
+	}
+	for _, b := range stream[length:] {
+		if b != 0 {
+			return nil, errors.New("non-zero padding after payload")
+		}
+	}
+	return stream[:length], nil
+}
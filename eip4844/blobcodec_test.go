@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+)
+
+// checkCanonical fails unless every field element is below the BLS modulus.
+func checkCanonical(t *testing.T, blobs []kzg4844.Blob) {
+	t.Helper()
+	modulus := blsModulus.FillBytes(make([]byte, bytesPerFieldElement))
+	for i := range blobs {
+		for fe := 0; fe < fieldElementsPerBlob; fe++ {
+			offset := fe * bytesPerFieldElement
+			if bytes.Compare(blobs[i][offset:offset+bytesPerFieldElement], modulus) >= 0 {
+				t.Fatalf("blob %d: field element %d is not below the modulus", i, fe)
+			}
+		}
+	}
+}
+
+func TestBlobCodecRoundTrip(t *testing.T) {
+	// The length prefix shares the first blob with the payload
+	capacity := usableBytesPerBlob - blobPayloadLengthBytes
+	tests := []struct {
+		name  string
+		size  int
+		blobs int
+	}{
+		{"empty", 0, 1},
+		{"one element", 31, 1},
+		{"one blob", capacity, 1},
+		{"one blob plus one byte", capacity + 1, 2},
+		{"maximum blobs", maxBlobsPerTxOsaka*usableBytesPerBlob - blobPayloadLengthBytes, maxBlobsPerTxOsaka},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.size)
+			rng.Read(data)
+			// All-ones chunks are above the modulus if written raw
+			for i := 0; i < len(data) && i < 64; i++ {
+				data[i] = 0xff
+			}
+
+			blobs := encodeBlobs(data)
+			if len(blobs) != tt.blobs {
+				t.Fatalf("encoded into %d blobs, want %d", len(blobs), tt.blobs)
+			}
+			checkCanonical(t, blobs)
+			decoded, err := decodeBlobs(blobs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, data) {
+				t.Fatal("decoded payload differs from the input")
+			}
+		})
+	}
+}
+
+func TestDecodeBlobsRejectsCorruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(blobs []kzg4844.Blob) []kzg4844.Blob
+	}{
+		{"no blobs", func([]kzg4844.Blob) []kzg4844.Blob { return nil }},
+		{"top byte set", func(b []kzg4844.Blob) []kzg4844.Blob { b[0][32] = 1; return b }},
+		{"length beyond capacity", func(b []kzg4844.Blob) []kzg4844.Blob { b[0][1] = 0xff; return b }},
+		{"extra blob", func(b []kzg4844.Blob) []kzg4844.Blob { return append(b, kzg4844.Blob{}) }},
+		{"trailing garbage", func(b []kzg4844.Blob) []kzg4844.Blob { b[0][len(b[0])-1] = 1; return b }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs := tt.corrupt(encodeBlobs([]byte("hello blobs")))
+			if _, err := decodeBlobs(blobs); err == nil {
+				t.Fatal("corrupted blobs decoded without error")
+			}
+		})
+	}
+}
+
+func FuzzBlobCodec(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte("hello blobs"))
+	f.Add(bytes.Repeat([]byte{0xff}, 2*usableBytesPerElement+1))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		blobs := encodeBlobs(data)
+		if len(blobs) != blobsNeeded(len(data)) {
+			t.Fatalf("encoded into %d blobs, want %d", len(blobs), blobsNeeded(len(data)))
+		}
+		checkCanonical(t, blobs)
+		decoded, err := decodeBlobs(blobs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatal("decoded payload differs from the input")
+		}
+	})
+}
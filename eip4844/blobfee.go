@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params/forks"
+)
+
+// blobFeeOptions controls how far ahead the blob base fee is projected and how
+// much headroom is added on top of the projection.
+type blobFeeOptions struct {
+	LookaheadBlocks     int    // blocks the transaction may wait before inclusion
+	SafetyMarginPercent int64  // extra margin on top of the worst projected fee
+	HistoryBlocks       uint64 // blocks of eth_feeHistory to take into account
+	BlockTime           uint64 // seconds between slots, used to pick the fork of future blocks
+}
+
+// blobFeeEstimate is the outcome of estimateBlobFeeCap.
+type blobFeeEstimate struct {
+	HeadNumber    uint64
+	ExcessBlobGas uint64     // excessBlobGas of the latest header
+	BlobGasUsed   uint64     // blobGasUsed of the latest header
+	Target, Max   int        // blobs per block of the active fork
+	CurrentFee    *big.Int   // blob base fee of the latest block, derived from its header
+	NodeFee       *big.Int   // eth_blobBaseFee, nil if the node doesn't support it
+	HistoryFees   []*big.Int // baseFeePerBlobGas from eth_feeHistory, nil if unavailable
+	Projected     []*big.Int // blob base fee of the next blocks if every one of them is full
+	BlobFeeCap    *big.Int
+}
+
+// estimateBlobFeeCap picks a BlobFeeCap that stays valid for opts.LookaheadBlocks
+// even if every block until then uses the maximum number of blobs.
+func estimateBlobFeeCap(ctx context.Context, client *ethclient.Client, cfg *params.ChainConfig, opts blobFeeOptions) (*blobFeeEstimate, error) {
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest header: %w", err)
+	}
+	if head.ExcessBlobGas == nil || head.BlobGasUsed == nil {
+		return nil, errors.New("latest header has no blob gas fields, is Cancun active?")
+	}
+	blobCfg := blobConfigAt(cfg, head.Time)
+	if blobCfg == nil {
+		return nil, fmt.Errorf("no blob schedule for block %d", head.Number)
+	}
+
+	est := &blobFeeEstimate{
+		HeadNumber:    head.Number.Uint64(),
+		ExcessBlobGas: *head.ExcessBlobGas,
+		BlobGasUsed:   *head.BlobGasUsed,
+		Target:        blobCfg.Target,
+		Max:           blobCfg.Max,
+		CurrentFee:    eip4844.CalcBlobFee(cfg, head),
+	}
+
+	// The node's own view is optional, not every endpoint implements it
+	if fee, err := client.BlobBaseFee(ctx); err == nil {
+		est.NodeFee = fee
+	}
+	if fees, err := blobFeeHistory(ctx, client, opts.HistoryBlocks); err == nil {
+		est.HistoryFees = fees
+	}
+
+	// The next block's excess is known exactly, the ones after that are projected
+	// assuming every block is full.
+	parent := head
+	for i := 0; i < opts.LookaheadBlocks; i++ {
+		time := parent.Time + opts.BlockTime
+		excess := eip4844.CalcExcessBlobGas(cfg, parent, time)
+		next := &types.Header{
+			Number:        new(big.Int).Add(parent.Number, big.NewInt(1)),
+			Time:          time,
+			ExcessBlobGas: &excess,
+			BlobGasUsed:   new(uint64),
+		}
+		if c := blobConfigAt(cfg, time); c != nil {
+			*next.BlobGasUsed = uint64(c.Max) * params.BlobTxBlobGasPerBlob
+		}
+		est.Projected = append(est.Projected, eip4844.CalcBlobFee(cfg, next))
+		parent = next
+	}
+
+	worst := new(big.Int).Set(est.CurrentFee)
+	for _, fee := range append(append([]*big.Int{est.NodeFee}, est.HistoryFees...), est.Projected...) {
+		if fee != nil && fee.Cmp(worst) > 0 {
+			worst.Set(fee)
+		}
+	}
+	feeCap := new(big.Int).Mul(worst, big.NewInt(100+opts.SafetyMarginPercent))
+	feeCap.Div(feeCap, big.NewInt(100))
+	if feeCap.Cmp(big.NewInt(params.BlobTxMinBlobGasprice)) < 0 {
+		feeCap.SetInt64(params.BlobTxMinBlobGasprice)
+	}
+	est.BlobFeeCap = feeCap
+	return est, nil
+}
+
+// blobConfigAt returns the target, max and update fraction of the fork active
+// at the given timestamp.
+func blobConfigAt(cfg *params.ChainConfig, time uint64) *params.BlobConfig {
+	if cfg.BlobScheduleConfig == nil {
+		return nil
+	}
+	switch cfg.LatestFork(time) {
+	case forks.Osaka:
+		return cfg.BlobScheduleConfig.Osaka
+	case forks.Prague:
+		return cfg.BlobScheduleConfig.Prague
+	case forks.Cancun:
+		return cfg.BlobScheduleConfig.Cancun
+	default:
+		return nil
+	}
+}
+
+// blobFeeHistory reads baseFeePerBlobGas from eth_feeHistory. ethclient's
+// FeeHistory drops the blob fields, so the call is made directly.
+func blobFeeHistory(ctx context.Context, client *ethclient.Client, blocks uint64) ([]*big.Int, error) {
+	var res struct {
+		BaseFeePerBlobGas []*hexutil.Big `json:"baseFeePerBlobGas"`
+	}
+	if err := client.Client().CallContext(ctx, &res, "eth_feeHistory", hexutil.Uint64(blocks), "latest", []float64{}); err != nil {
+		return nil, err
+	}
+	if len(res.BaseFeePerBlobGas) == 0 {
+		return nil, errors.New("node did not return blob fee history")
+	}
+	fees := make([]*big.Int, len(res.BaseFeePerBlobGas))
+	for i, fee := range res.BaseFeePerBlobGas {
+		fees[i] = fee.ToInt()
+	}
+	return fees, nil
+}
+
+// String summarizes the estimate for logging.
+func (e *blobFeeEstimate) String() string {
+	s := fmt.Sprintf("block %d: excessBlobGas=%d blobGasUsed=%d (target %d, max %d blobs)\n",
+		e.HeadNumber, e.ExcessBlobGas, e.BlobGasUsed, e.Target, e.Max)
+	s += fmt.Sprintf("  blob base fee: %s wei", e.CurrentFee)
+	if e.NodeFee != nil {
+		s += fmt.Sprintf(", eth_blobBaseFee: %s wei", e.NodeFee)
+	}
+	if len(e.Projected) > 0 {
+		s += fmt.Sprintf(", worst case in %d blocks: %s wei", len(e.Projected), e.Projected[len(e.Projected)-1])
+	}
+	s += fmt.Sprintf("\n  BlobFeeCap: %s wei", e.BlobFeeCap)
+	return s
+}
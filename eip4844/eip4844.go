@@ -2,26 +2,22 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
+	"flag"
 	"fmt"
 	"log"
 	"math/big"
+	"os"
 	"time"
 	"transactiontypes/account" // Assuming this package provides GetAccount
+	"transactiontypes/builder"
+	"transactiontypes/journal"
+	"transactiontypes/monitor"
+	"transactiontypes/policy"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto/kzg4844"
 	"github.com/ethereum/go-ethereum/ethclient"
-
-	// IMPORTANT: Import the C-KZG-4844 Go bindings directly for setup
-	kzgBindings "github.com/ethereum/c-kzg-4844/v2/bindings/go"
-	"github.com/holiman/uint256"
 	"github.com/samber/lo"
-	// You might need to import kzg to generate actual blob data and commitments
-	// For this example, we'll use a placeholder.
-	// "github.com/ethereum/go-ethereum/crypto/kzg4844"
 )
 
 // mined Tx:
@@ -32,26 +28,37 @@ const (
 	NodeRPCURL     = "https://eth-sepolia.public.blastapi.io" // Or a Dencun-enabled testnet like Sepolia
 	SepoliaChainID = 11155111                                 // Sepolia Testnet Chain ID
 
-	// Make sure you have this file in the specified path!
-	TrustedSetupFilePath = "./trusted_setup.txt"
+	// Data sent in the blob transaction
+	BlobPayload = "Hello, EIP-4844 Blob Transaction on Sepolia! This is some arbitrary data for the blob payload."
+
+	// Beacon node REST API, needed to read blobs back after inclusion
+	BeaconAPIURL = "http://localhost:5052"
+
+	// BlobFeeCap estimation: survive this many full blocks, plus a safety margin
+	BlobFeeLookaheadBlocks     = 5
+	BlobFeeSafetyMarginPercent = 20
 )
 
+const usage = `Usage:
+  eip4844 [flags] [send]           encode a payload into blobs and send a blob transaction
+  eip4844 [flags] receipt <txhash> report blob and execution fees of an included blob transaction
+  eip4844 [flags] fetch <txhash>   read the blobs of a transaction back from a beacon node and decode them
+  eip4844 [flags] point-eval <z> [payload]
+                                   build and check the point evaluation precompile input for a blob at z
+
+Flags:`
+
 func main() {
-	// --- KZG Trusted Setup Initialization (CRITICAL FOR BLOB TXS) ---
-	fmt.Println("Loading KZG trusted setup using c-kzg-4844 bindings...")
-	// LoadTrustedSetupFile returns an error. It internally sets up the KZG settings
-	// which the go-ethereum/crypto/kzg4844 package then uses.
-	// The '0' argument is for 'precompute', typically 0 for default.
-	err := kzgBindings.LoadTrustedSetupFile(TrustedSetupFilePath, 0)
-	if err != nil {
-		log.Fatalf("Failed to load KZG trusted setup from %s: %v", TrustedSetupFilePath, err)
+	// The KZG trusted setup is embedded in the binary and loaded on first use.
+	// Devnets with their own ceremony output can pass it with -trusted-setup.
+	flag.StringVar(&kzgConfig.Backend, "kzg-backend", kzgBackendGo, "KZG implementation: gokzg or ckzg")
+	flag.StringVar(&kzgConfig.TrustedSetupFile, "trusted-setup", "", "custom trusted setup file in c-kzg format (ckzg backend only)")
+	beaconURL := flag.String("beacon", BeaconAPIURL, "beacon node API URL used by fetch")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
 	}
-	fmt.Println("KZG trusted setup loaded successfully.")
-	// --- End KZG Trusted Setup Initialization ---
-
-	acc2Addr, acc2Priv := account.GetAccount(2)
-	// to := lo.ToPtr(common.HexToAddress("0x0fd9e8d3af1aaee056eb9e802c3a762a667b1904"))
-	to := lo.ToPtr(common.HexToAddress("0x7F8b1ca29F95274E06367b60fC4a539E4910FD0c"))
+	flag.Parse()
 
 	ctx := context.Background()
 	client, err := ethclient.Dial(NodeRPCURL)
@@ -59,6 +66,66 @@ func main() {
 		log.Fatal("Failed to connect to Ethereum node:", err)
 	}
 
+	switch flag.Arg(0) {
+	case "", "send":
+		sendBlobTx(ctx, client)
+	case "receipt":
+		if flag.NArg() != 2 {
+			log.Fatal("Usage: eip4844 receipt <txhash>")
+		}
+		report, err := blobReceipt(ctx, client, common.HexToHash(flag.Arg(1)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		report.Print()
+	case "fetch":
+		if flag.NArg() != 2 {
+			log.Fatal("Usage: eip4844 fetch <txhash>")
+		}
+		payload, err := fetchBlobs(ctx, client, newBeaconClient(*beaconURL), common.HexToHash(flag.Arg(1)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		printPayload(payload)
+	case "point-eval":
+		if flag.NArg() < 2 || flag.NArg() > 3 {
+			log.Fatal("Usage: eip4844 point-eval <z> [payload]")
+		}
+		z, ok := new(big.Int).SetString(flag.Arg(1), 0)
+		if !ok {
+			log.Fatalf("Invalid evaluation point: %s", flag.Arg(1))
+		}
+		payload := BlobPayload
+		if flag.NArg() == 3 {
+			payload = flag.Arg(2)
+		}
+		blobs := encodeBlobs([]byte(payload))
+		if len(blobs) != 1 {
+			log.Fatal("Payload does not fit into a single blob")
+		}
+		eval, err := computePointEvaluation(&blobs[0], z)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Versioned hash:", eval.VersionedHash.Hex())
+		fmt.Printf("z: 0x%x\ny: 0x%x\n", eval.Z, eval.Y)
+		fmt.Printf("Precompile input: 0x%x\n", eval.Input())
+		if err := callPointEvaluation(ctx, client, eval.Input()); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Point evaluation precompile accepted the proof")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// sendBlobTx encodes a payload into blobs and sends it in a blob transaction.
+func sendBlobTx(ctx context.Context, client *ethclient.Client) {
+	acc2Addr, acc2Priv := account.GetAccount(2)
+	// to := lo.ToPtr(common.HexToAddress("0x0fd9e8d3af1aaee056eb9e802c3a762a667b1904"))
+	to := lo.ToPtr(common.HexToAddress("0x7F8b1ca29F95274E06367b60fC4a539E4910FD0c"))
+
 	nonce, err := client.PendingNonceAt(ctx, lo.FromPtr(acc2Addr))
 	if err != nil {
 		log.Fatal("Failed to fetch nonce:", err)
@@ -86,83 +153,106 @@ func main() {
 	chainID := big.NewInt(SepoliaChainID)
 
 	// --- EIP-4844 Specifics ---
-	var blobData kzg4844.Blob
-	content := []byte("Hello, EIP-4844 Blob Transaction on Sepolia! This is some arbitrary data for the blob payload.")
-	if len(content) > 131072 {
-		log.Fatalf("Content size (%d) exceeds single blob size (%d)", len(content), 131072)
-	}
-	copy(blobData[:], content)
+	content := []byte(BlobPayload)
+	// Raw bytes can't be copied into a blob directly: every 32 byte field element
+	// must stay below the BLS modulus. encodeBlobs packs 31 bytes per element
+	// and spreads larger payloads over several blobs.
+	blobs := encodeBlobs(content)
 
-	// Pass kzg4844.Blob by value to BlobToCommitment
-	kzgCommitment, err := kzg4844.BlobToCommitment(&blobData)
+	// The per-transaction blob limit depends on the active fork
+	chainConfig, err := chainConfigFor(SepoliaChainID)
 	if err != nil {
-		log.Fatal("Failed to compute KZG commitment:", err)
+		log.Fatal(err)
 	}
-
-	var kzgProof kzg4844.Proof
-	var commitmentAsPoint kzg4844.Point
-	copy(commitmentAsPoint[:], kzgCommitment[:])
-
-	kzgProof, err = kzg4844.ComputeBlobProof(&blobData, kzgCommitment)
+	head, err := client.HeaderByNumber(ctx, nil)
 	if err != nil {
-		log.Fatal("Failed to compute KZG proof:", err)
+		log.Fatal("Failed to fetch latest header:", err)
 	}
 
-	// Create a new SHA256 hasher.
-	hasher := sha256.New()
-	blobVersionedHash := kzg4844.CalcBlobHashV1(hasher, &kzgCommitment) // Pass commitment by pointer
-	blobVersionedHashes := []common.Hash{common.Hash(blobVersionedHash)}
-
-	// The BlobTxSidecar contains the actual blobs and KZG proofs.
+	// The BlobTxSidecar contains the actual blobs, KZG commitments and proofs.
 	// It is transmitted alongside the transaction but not part of the RLP-encoded transaction itself.
 	// The go-ethereum client handles attaching this when sending a BlobTx.
-	sidecar := &types.BlobTxSidecar{
-		Blobs:       []kzg4844.Blob{blobData},            // Placeholder: Real blobs are kzg4844.Blob
-		Commitments: []kzg4844.Commitment{kzgCommitment}, // Placeholder: Real commitments are KZG.Commitment
-		Proofs:      []kzg4844.Proof{kzgProof},           // Placeholder: Real proofs are KZG.Proof
+	// From Osaka on (PeerDAS) the pool expects version 1 sidecars with cell proofs.
+	sidecarVersion := sidecarVersionAt(chainConfig, head)
+	sidecar, blobVersionedHashes, err := buildSidecar(blobs, maxBlobsPerTx(chainConfig, head), sidecarVersion)
+	if err != nil {
+		log.Fatal("Failed to build blob sidecar:", err)
 	}
-	// You will need to calculate real commitments and proofs if you want to send this on a real network.
+	fmt.Printf("Built version %d sidecar with %d blob(s) for %d bytes of content\n", sidecarVersion, len(blobs), len(content))
 
-	value := uint256.MustFromBig(big.NewInt(100000000000000))
-
-	gasLimit, err := client.EstimateGas(ctx, ethereum.CallMsg{
-		From:  *acc2Addr,
-		To:    to,
-		Value: value.ToBig(),
+	// Blob gas is priced separately from execution gas, estimate it from recent blocks
+	blobFee, err := estimateBlobFeeCap(ctx, client, chainConfig, blobFeeOptions{
+		LookaheadBlocks:     BlobFeeLookaheadBlocks,
+		SafetyMarginPercent: BlobFeeSafetyMarginPercent,
+		HistoryBlocks:       5,
+		BlockTime:           12,
 	})
 	if err != nil {
-		log.Fatal("Failed to fetch gas limit:", err)
+		log.Fatal("Failed to estimate blob fee:", err)
 	}
+	fmt.Println("Blob fee:", blobFee)
 
 	fmt.Println("nonce:", nonce)
 
-	// Construct the EIP-4844 transaction
-	tx := types.BlobTx{
-		ChainID:    uint256.NewInt(SepoliaChainID),
+	// Describe the EIP-4844 transaction
+	req := &builder.Request{
+		Type:       types.BlobTxType,
+		ChainID:    chainID,
+		From:       *acc2Addr,
+		To:         to,
 		Nonce:      nonce,
-		GasTipCap:  uint256.MustFromBig(gasTipCap),
-		GasFeeCap:  uint256.MustFromBig(gasFeeCap), // Double the gas fee cap for safety
-		Gas:        gasLimit,
-		To:         *to,
-		Value:      value,
-		BlobFeeCap: uint256.MustFromBig(big.NewInt(2000000)),
+		Value:      big.NewInt(100000000000000),
+		GasTipCap:  gasTipCap,
+		GasFeeCap:  gasFeeCap,
+		BlobFeeCap: blobFee.BlobFeeCap,
 		BlobHashes: blobVersionedHashes,
+		Sidecar:    sidecar,
 	}
 
-	// Convert it into a full types.Transaction object
-	eip4844Tx := types.NewTx(&tx)
+	// Blob transactions can carry an access list too. This estimates the gas
+	// limit and attaches the generated list only if it pays for itself.
+	accessList, err := req.GenerateAccessList(ctx, client, builder.EnvAt(chainConfig, head))
+	if err != nil {
+		log.Fatal("Failed to create access list:", err)
+	}
+	fmt.Println(accessList)
 
-	// Attach the BlobTxSidecar to the transaction. This is crucial for sending blobs.
-	eip4844TxWithSidecar := eip4844Tx.WithBlobTxSidecar(sidecar)
+	// Never sign a transaction whose sidecar would be rejected by the blob pool
+	if err := verifySidecar(sidecar, req.BlobHashes); err != nil {
+		log.Fatal("Invalid blob sidecar:", err)
+	}
+
+	spending, err := policy.Default()
+	if err != nil {
+		log.Fatal("Failed to load spending policy:", err)
+	}
+	if err := spending.Check(req); err != nil {
+		log.Fatal(err)
+	}
+
+	// Build the transaction, the sidecar is carried along for broadcasting
+	eip4844TxWithSidecar, err := req.Build()
+	if err != nil {
+		log.Fatal("Failed to build transaction:", err)
+	}
+
+	signer := types.LatestSignerForChainID(chainID)
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	if err := jrnl.RecordBuilt(eip4844TxWithSidecar, *acc2Addr, signer); err != nil {
+		log.Fatal("Failed to journal transaction:", err)
+	}
 
 	// Sign the transaction
-	signedTx, err := types.SignTx(eip4844TxWithSidecar, types.LatestSignerForChainID(chainID), acc2Priv)
+	signedTx, err := types.SignTx(eip4844TxWithSidecar, signer, acc2Priv)
 	if err != nil {
 		log.Fatal("Failed to sign transaction:", err)
 	}
 
 	// Broadcast the transaction
-	err = client.SendTransaction(ctx, signedTx)
+	err = jrnl.Broadcast(ctx, client, NodeRPCURL, signedTx, *acc2Addr)
 	if err != nil {
 		log.Fatal("Broadcast failed:", err)
 	}
@@ -171,14 +261,16 @@ func main() {
 	fmt.Println("Tx hash:", signedTx.Hash().Hex())
 
 	// Optionally wait for inclusion
-	time.Sleep(10 * time.Second)
-	receipt, err := client.TransactionReceipt(ctx, signedTx.Hash())
+	waitCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if _, err := monitor.Wait(waitCtx, client, signedTx, *acc2Addr, monitor.StatusIncluded); err != nil {
+		fmt.Println("Tx not mined yet:", err)
+		return
+	}
+	report, err := blobReceipt(ctx, client, signedTx.Hash())
 	if err != nil {
 		fmt.Println("Tx not mined yet or error fetching receipt:", err)
 	} else {
-		fmt.Println("Tx mined in block:", receipt.BlockNumber)
-		fmt.Println("Blob Gas Used:", receipt.BlobGasUsed)
-		fmt.Println("Blob Gas Price:", receipt.BlobGasPrice)
-		fmt.Println("Excess Blob Gas:", receipt.BlobGasUsed)
+		report.Print()
 	}
 }
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	ckzg "github.com/ethereum/c-kzg-4844/v2/bindings/go"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+)
+
+// embeddedTrustedSetup is the mainnet KZG ceremony output in the c-kzg text
+// format, so the example works regardless of the working directory.
+//
+//go:embed trusted_setup.txt
+var embeddedTrustedSetup []byte
+
+const (
+	kzgBackendGo = "gokzg" // go-eth-kzg through go-ethereum's kzg4844 package
+	kzgBackendC  = "ckzg"  // c-kzg-4844 bindings, loaded with our own trusted setup
+
+	// ckzgPrecompute is the multiplication table size passed to c-kzg, the
+	// same trade-off go-ethereum makes.
+	ckzgPrecompute = 6
+)
+
+// kzgBackend is the set of KZG operations the blob tooling needs.
+type kzgBackend interface {
+	BlobToCommitment(blob *kzg4844.Blob) (kzg4844.Commitment, error)
+	ComputeBlobProof(blob *kzg4844.Blob, commitment kzg4844.Commitment) (kzg4844.Proof, error)
+	VerifyBlobProof(blob *kzg4844.Blob, commitment kzg4844.Commitment, proof kzg4844.Proof) error
+	ComputeCellProofs(blob *kzg4844.Blob) ([]kzg4844.Proof, error)
+	VerifyCellProofs(blobs []kzg4844.Blob, commitments []kzg4844.Commitment, proofs []kzg4844.Proof) error
+	ComputeProof(blob *kzg4844.Blob, point kzg4844.Point) (kzg4844.Proof, kzg4844.Claim, error)
+	VerifyProof(commitment kzg4844.Commitment, point kzg4844.Point, claim kzg4844.Claim, proof kzg4844.Proof) error
+}
+
+// kzgConfig selects the backend and trusted setup. It has to be set before the
+// first KZG operation, after that the loaded setup is reused.
+var kzgConfig = struct {
+	Backend          string
+	TrustedSetupFile string // optional custom setup for devnets, c-kzg only
+}{
+	Backend: kzgBackendGo,
+}
+
+var (
+	kzgOnce sync.Once
+	kzgImpl kzgBackend
+	kzgErr  error
+)
+
+// loadKZG returns the configured backend, loading the trusted setup on first use.
+func loadKZG() (kzgBackend, error) {
+	kzgOnce.Do(func() {
+		switch kzgConfig.Backend {
+		case kzgBackendGo:
+			if kzgConfig.TrustedSetupFile != "" {
+				kzgErr = errors.New("custom trusted setups need the ckzg backend")
+				return
+			}
+			// kzg4844 ships its own copy of the mainnet setup
+			kzgImpl = goKZG{}
+		case kzgBackendC:
+			setup := embeddedTrustedSetup
+			if kzgConfig.TrustedSetupFile != "" {
+				if setup, kzgErr = os.ReadFile(kzgConfig.TrustedSetupFile); kzgErr != nil {
+					return
+				}
+			}
+			if kzgErr = loadCKZGSetup(setup); kzgErr == nil {
+				kzgImpl = cKZG{}
+			}
+		default:
+			kzgErr = fmt.Errorf("unknown KZG backend %q, use %s or %s", kzgConfig.Backend, kzgBackendGo, kzgBackendC)
+		}
+	})
+	return kzgImpl, kzgErr
+}
+
+// loadCKZGSetup parses a trusted setup in the c-kzg text format: the G1 and G2
+// point counts, then the G1 Lagrange, G2 monomial and G1 monomial points, one
+// hex encoded point per line.
+func loadCKZGSetup(setup []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(setup))
+	readInt := func() (int, error) {
+		if !scanner.Scan() {
+			return 0, errors.New("trusted setup: unexpected end of file")
+		}
+		return strconv.Atoi(scanner.Text())
+	}
+	readPoints := func(n int) ([]byte, error) {
+		var out []byte
+		for i := 0; i < n; i++ {
+			if !scanner.Scan() {
+				return nil, errors.New("trusted setup: unexpected end of file")
+			}
+			point, err := hex.DecodeString(scanner.Text())
+			if err != nil {
+				return nil, fmt.Errorf("trusted setup: %w", err)
+			}
+			out = append(out, point...)
+		}
+		return out, nil
+	}
+
+	numG1, err := readInt()
+	if err != nil {
+		return err
+	}
+	numG2, err := readInt()
+	if err != nil {
+		return err
+	}
+	g1Lagrange, err := readPoints(numG1)
+	if err != nil {
+		return err
+	}
+	g2Monomial, err := readPoints(numG2)
+	if err != nil {
+		return err
+	}
+	g1Monomial, err := readPoints(numG1)
+	if err != nil {
+		return err
+	}
+	return ckzg.LoadTrustedSetup(g1Monomial, g1Lagrange, g2Monomial, ckzgPrecompute)
+}
+
+// goKZG forwards to go-ethereum's kzg4844 package with its default Go backend.
+type goKZG struct{}
+
+func (goKZG) BlobToCommitment(blob *kzg4844.Blob) (kzg4844.Commitment, error) {
+	return kzg4844.BlobToCommitment(blob)
+}
+
+func (goKZG) ComputeBlobProof(blob *kzg4844.Blob, commitment kzg4844.Commitment) (kzg4844.Proof, error) {
+	return kzg4844.ComputeBlobProof(blob, commitment)
+}
+
+func (goKZG) VerifyBlobProof(blob *kzg4844.Blob, commitment kzg4844.Commitment, proof kzg4844.Proof) error {
+	return kzg4844.VerifyBlobProof(blob, commitment, proof)
+}
+
+func (goKZG) ComputeCellProofs(blob *kzg4844.Blob) ([]kzg4844.Proof, error) {
+	return kzg4844.ComputeCellProofs(blob)
+}
+
+func (goKZG) VerifyCellProofs(blobs []kzg4844.Blob, commitments []kzg4844.Commitment, proofs []kzg4844.Proof) error {
+	return kzg4844.VerifyCellProofs(blobs, commitments, proofs)
+}
+
+func (goKZG) ComputeProof(blob *kzg4844.Blob, point kzg4844.Point) (kzg4844.Proof, kzg4844.Claim, error) {
+	return kzg4844.ComputeProof(blob, point)
+}
+
+func (goKZG) VerifyProof(commitment kzg4844.Commitment, point kzg4844.Point, claim kzg4844.Claim, proof kzg4844.Proof) error {
+	return kzg4844.VerifyProof(commitment, point, claim, proof)
+}
+
+// cKZG calls the c-kzg-4844 bindings directly, using whatever setup
+// loadCKZGSetup loaded.
+type cKZG struct{}
+
+func (cKZG) BlobToCommitment(blob *kzg4844.Blob) (kzg4844.Commitment, error) {
+	commitment, err := ckzg.BlobToKZGCommitment((*ckzg.Blob)(blob))
+	return kzg4844.Commitment(commitment), err
+}
+
+func (cKZG) ComputeBlobProof(blob *kzg4844.Blob, commitment kzg4844.Commitment) (kzg4844.Proof, error) {
+	proof, err := ckzg.ComputeBlobKZGProof((*ckzg.Blob)(blob), ckzg.Bytes48(commitment))
+	return kzg4844.Proof(proof), err
+}
+
+func (cKZG) VerifyBlobProof(blob *kzg4844.Blob, commitment kzg4844.Commitment, proof kzg4844.Proof) error {
+	valid, err := ckzg.VerifyBlobKZGProof((*ckzg.Blob)(blob), ckzg.Bytes48(commitment), ckzg.Bytes48(proof))
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid proof")
+	}
+	return nil
+}
+
+func (cKZG) ComputeCellProofs(blob *kzg4844.Blob) ([]kzg4844.Proof, error) {
+	_, cellProofs, err := ckzg.ComputeCellsAndKZGProofs((*ckzg.Blob)(blob))
+	if err != nil {
+		return nil, err
+	}
+	proofs := make([]kzg4844.Proof, len(cellProofs))
+	for i, proof := range cellProofs {
+		proofs[i] = kzg4844.Proof(proof)
+	}
+	return proofs, nil
+}
+
+func (cKZG) VerifyCellProofs(blobs []kzg4844.Blob, commitments []kzg4844.Commitment, proofs []kzg4844.Proof) error {
+	if len(proofs) != len(blobs)*ckzg.CellsPerExtBlob || len(commitments) != len(blobs) {
+		return errors.New("invalid number of commitments or cell proofs")
+	}
+	var (
+		proofBytes  = make([]ckzg.Bytes48, 0, len(proofs))
+		commitBytes = make([]ckzg.Bytes48, 0, len(proofs))
+		cellIndices = make([]uint64, 0, len(proofs))
+		cells       = make([]ckzg.Cell, 0, len(proofs))
+	)
+	for _, proof := range proofs {
+		proofBytes = append(proofBytes, ckzg.Bytes48(proof))
+	}
+	for i := range blobs {
+		blobCells, err := ckzg.ComputeCells((*ckzg.Blob)(&blobs[i]))
+		if err != nil {
+			return err
+		}
+		cells = append(cells, blobCells[:]...)
+		for idx := range blobCells {
+			commitBytes = append(commitBytes, ckzg.Bytes48(commitments[i]))
+			cellIndices = append(cellIndices, uint64(idx))
+		}
+	}
+	valid, err := ckzg.VerifyCellKZGProofBatch(commitBytes, cellIndices, cells, proofBytes)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid cell proofs")
+	}
+	return nil
+}
+
+func (cKZG) ComputeProof(blob *kzg4844.Blob, point kzg4844.Point) (kzg4844.Proof, kzg4844.Claim, error) {
+	proof, claim, err := ckzg.ComputeKZGProof((*ckzg.Blob)(blob), ckzg.Bytes32(point))
+	return kzg4844.Proof(proof), kzg4844.Claim(claim), err
+}
+
+func (cKZG) VerifyProof(commitment kzg4844.Commitment, point kzg4844.Point, claim kzg4844.Claim, proof kzg4844.Proof) error {
+	valid, err := ckzg.VerifyKZGProof(ckzg.Bytes48(commitment), ckzg.Bytes32(point), ckzg.Bytes32(claim), ckzg.Bytes48(proof))
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid proof")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: UNSPECIFIED
+pragma solidity ^0.8.24;
+
+// Proves values inside blobs through the EIP-4844 point evaluation precompile.
+// The 192 byte input is produced by `eip4844 point-eval`.
+contract PointEvaluationVerifier {
+    address constant POINT_EVALUATION = address(0x0a);
+    uint256 constant FIELD_ELEMENTS_PER_BLOB = 4096;
+    uint256 constant BLS_MODULUS =
+        52435875175126190479447740508185965837690552500527637822603658699938581184513;
+
+    event PointVerified(bytes32 versionedHash, bytes32 z, bytes32 y);
+
+    // Verifies a raw precompile input: versioned_hash | z | y | commitment | proof
+    function verify(bytes memory input) public view returns (bool) {
+        require(input.length == 192, "Invalid input length");
+
+        (bool ok, bytes memory out) = POINT_EVALUATION.staticcall(input);
+        if (!ok || out.length != 64) {
+            return false;
+        }
+        (uint256 fieldElements, uint256 modulus) = abi.decode(out, (uint256, uint256));
+        return fieldElements == FIELD_ELEMENTS_PER_BLOB && modulus == BLS_MODULUS;
+    }
+
+    // Verifies that blob `index` of the current blob transaction evaluates to y at z.
+    // Must be called from within the blob transaction, where blobhash() is available.
+    function verifyBlob(
+        uint256 index,
+        bytes32 z,
+        bytes32 y,
+        bytes calldata commitment,
+        bytes calldata proof
+    ) external returns (bool) {
+        bytes32 versionedHash = blobhash(index);
+        require(versionedHash != bytes32(0), "No blob at index");
+        require(commitment.length == 48 && proof.length == 48, "Invalid commitment or proof");
+
+        bool valid = verify(abi.encodePacked(versionedHash, z, y, commitment, proof));
+        if (valid) {
+            emit PointVerified(versionedHash, z, y);
+        }
+        return valid;
+    }
+}
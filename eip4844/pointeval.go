@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// pointEvaluationPrecompile is the EIP-4844 point evaluation precompile.
+var pointEvaluationPrecompile = common.BytesToAddress([]byte{0x0a})
+
+// blsModulus is the order of the BLS12-381 scalar field. Evaluation points
+// must be smaller than it.
+var blsModulus, _ = new(big.Int).SetString("52435875175126190479447740508185965837690552500527637822603658699938581184513", 10)
+
+// pointEvaluationInputSize is versioned hash, z, y, commitment and proof.
+const pointEvaluationInputSize = 32 + 32 + 32 + 48 + 48
+
+// pointEvaluation is a proof that the polynomial committed to by a blob
+// evaluates to Y at Z.
+type pointEvaluation struct {
+	VersionedHash common.Hash
+	Z             kzg4844.Point
+	Y             kzg4844.Claim
+	Commitment    kzg4844.Commitment
+	Proof         kzg4844.Proof
+}
+
+// computePointEvaluation evaluates blob at z and returns the opening proof.
+func computePointEvaluation(blob *kzg4844.Blob, z *big.Int) (*pointEvaluation, error) {
+	if z.Sign() < 0 || z.Cmp(blsModulus) >= 0 {
+		return nil, fmt.Errorf("evaluation point %s is outside the BLS scalar field", z)
+	}
+	kzg, err := loadKZG()
+	if err != nil {
+		return nil, err
+	}
+
+	var point kzg4844.Point
+	z.FillBytes(point[:])
+
+	commitment, err := kzg.BlobToCommitment(blob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute commitment: %w", err)
+	}
+	proof, claim, err := kzg.ComputeProof(blob, point)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute proof: %w", err)
+	}
+	// Check locally before handing it to a contract
+	if err := kzg.VerifyProof(commitment, point, claim, proof); err != nil {
+		return nil, fmt.Errorf("computed proof does not verify: %w", err)
+	}
+
+	return &pointEvaluation{
+		VersionedHash: kzg4844.CalcBlobHashV1(sha256.New(), &commitment),
+		Z:             point,
+		Y:             claim,
+		Commitment:    commitment,
+		Proof:         proof,
+	}, nil
+}
+
+// Input returns the 192 byte input of the point evaluation precompile:
+// versioned_hash | z | y | commitment | proof.
+func (p *pointEvaluation) Input() []byte {
+	input := make([]byte, 0, pointEvaluationInputSize)
+	input = append(input, p.VersionedHash[:]...)
+	input = append(input, p.Z[:]...)
+	input = append(input, p.Y[:]...)
+	input = append(input, p.Commitment[:]...)
+	input = append(input, p.Proof[:]...)
+	return input
+}
+
+// callPointEvaluation runs the input through the precompile with eth_call. On
+// success the precompile returns FIELD_ELEMENTS_PER_BLOB and BLS_MODULUS.
+func callPointEvaluation(ctx context.Context, client *ethclient.Client, input []byte) error {
+	precompile := pointEvaluationPrecompile
+	res, err := client.CallContract(ctx, ethereum.CallMsg{To: &precompile, Data: input}, nil)
+	if err != nil {
+		return fmt.Errorf("point evaluation failed: %w", err)
+	}
+	if len(res) != 64 {
+		return fmt.Errorf("unexpected point evaluation output length %d", len(res))
+	}
+	if new(big.Int).SetBytes(res[:32]).Uint64() != fieldElementsPerBlob || new(big.Int).SetBytes(res[32:]).Cmp(blsModulus) != 0 {
+		return fmt.Errorf("unexpected point evaluation output %x", res)
+	}
+	return nil
+}
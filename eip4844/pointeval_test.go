@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newCancunClient starts a simulated chain at Cancun and connects to it over
+// IPC, callPointEvaluation takes a full ethclient.
+func newCancunClient(t *testing.T) *ethclient.Client {
+	t.Helper()
+	ipc := filepath.Join(t.TempDir(), "sim.ipc")
+	sim := simulated.NewBackend(nil, func(nodeConf *node.Config, ethConf *ethconfig.Config) {
+		nodeConf.IPCPath = ipc
+		cancun := *params.AllDevChainProtocolChanges
+		cancun.PragueTime, cancun.OsakaTime = nil, nil
+		cancun.BlobScheduleConfig = &params.BlobScheduleConfig{Cancun: params.DefaultCancunBlobConfig}
+		ethConf.Genesis.Config = &cancun
+	})
+	t.Cleanup(func() { sim.Close() })
+	client, err := ethclient.Dial(ipc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestCallPointEvaluation(t *testing.T) {
+	eval, err := computePointEvaluation(&encodeBlobs([]byte("point evaluation"))[0], big.NewInt(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newCancunClient(t)
+
+	valid := eval.Input()
+	if len(valid) != pointEvaluationInputSize {
+		t.Fatalf("input is %d bytes, want %d", len(valid), pointEvaluationInputSize)
+	}
+	if err := callPointEvaluation(context.Background(), client, valid); err != nil {
+		t.Fatalf("valid input rejected: %v", err)
+	}
+
+	corrupted := eval.Input()
+	corrupted[len(corrupted)-1] ^= 0x01 // last byte of the proof
+	err = callPointEvaluation(context.Background(), client, corrupted)
+	if err == nil || !strings.Contains(err.Error(), "point evaluation failed") {
+		t.Fatalf("corrupted proof: err = %v, want a failed call", err)
+	}
+}
+
+func TestComputePointEvaluationOutsideField(t *testing.T) {
+	blob := &encodeBlobs(nil)[0]
+	if _, err := computePointEvaluation(blob, blsModulus); err == nil {
+		t.Fatal("evaluation point equal to the modulus accepted")
+	}
+	if _, err := computePointEvaluation(blob, big.NewInt(-1)); err == nil {
+		t.Fatal("negative evaluation point accepted")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// blobReceiptReport describes what an included blob transaction actually cost.
+type blobReceiptReport struct {
+	TxHash      common.Hash
+	BlockNumber *big.Int
+	Status      uint64
+	Blobs       int
+
+	// Block level blob gas accounting, from the header
+	BlockExcessBlobGas uint64
+	BlockBlobGasUsed   uint64
+	HeaderBlobBaseFee  *big.Int // derived from excessBlobGas, nil for unknown chains
+
+	// Transaction level costs, from the receipt
+	BlobGasUsed       uint64
+	BlobGasPrice      *big.Int
+	BlobFee           *big.Int // blobGasUsed × blobGasPrice
+	GasUsed           uint64
+	EffectiveGasPrice *big.Int
+	ExecutionFee      *big.Int // gasUsed × effectiveGasPrice
+
+	// What the sender was willing to pay
+	BlobFeeCap    *big.Int
+	MaxBlobFee    *big.Int // blobGasUsed × BlobFeeCap
+	BlobFeeMargin *big.Int // BlobFeeCap - blobGasPrice
+}
+
+// blobReceipt collects the receipt, transaction and header of an included
+// blob transaction into a report.
+func blobReceipt(ctx context.Context, client *ethclient.Client, txHash common.Hash) (*blobReceiptReport, error) {
+	tx, pending, err := client.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	if tx.Type() != types.BlobTxType {
+		return nil, fmt.Errorf("transaction %s is type %d, not a blob transaction", txHash.Hex(), tx.Type())
+	}
+	if pending {
+		return nil, fmt.Errorf("transaction %s is still pending", txHash.Hex())
+	}
+
+	receipt, err := client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch receipt: %w", err)
+	}
+	header, err := client.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch block header: %w", err)
+	}
+
+	r := &blobReceiptReport{
+		TxHash:            txHash,
+		BlockNumber:       receipt.BlockNumber,
+		Status:            receipt.Status,
+		Blobs:             len(tx.BlobHashes()),
+		BlobGasUsed:       receipt.BlobGasUsed,
+		BlobGasPrice:      receipt.BlobGasPrice,
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: receipt.EffectiveGasPrice,
+		BlobFeeCap:        tx.BlobGasFeeCap(),
+	}
+	if header.ExcessBlobGas != nil {
+		r.BlockExcessBlobGas = *header.ExcessBlobGas
+	}
+	if header.BlobGasUsed != nil {
+		r.BlockBlobGasUsed = *header.BlobGasUsed
+	}
+	if cfg, err := chainConfigFor(tx.ChainId().Uint64()); err == nil && header.ExcessBlobGas != nil && blobConfigAt(cfg, header.Time) != nil {
+		r.HeaderBlobBaseFee = eip4844.CalcBlobFee(cfg, header)
+	}
+
+	// Some nodes leave blobGasPrice out of receipts, fall back to the header
+	if r.BlobGasPrice == nil {
+		r.BlobGasPrice = r.HeaderBlobBaseFee
+	}
+	if r.BlobGasPrice != nil {
+		r.BlobFee = new(big.Int).Mul(new(big.Int).SetUint64(r.BlobGasUsed), r.BlobGasPrice)
+		r.BlobFeeMargin = new(big.Int).Sub(r.BlobFeeCap, r.BlobGasPrice)
+	}
+	if r.EffectiveGasPrice != nil {
+		r.ExecutionFee = new(big.Int).Mul(new(big.Int).SetUint64(r.GasUsed), r.EffectiveGasPrice)
+	}
+	r.MaxBlobFee = new(big.Int).Mul(new(big.Int).SetUint64(r.BlobGasUsed), r.BlobFeeCap)
+	return r, nil
+}
+
+// Print writes the report to stdout.
+func (r *blobReceiptReport) Print() {
+	fmt.Println("Tx hash:", r.TxHash.Hex())
+	fmt.Println("Tx mined in block:", r.BlockNumber, "status:", r.Status)
+	fmt.Println("Blobs:", r.Blobs)
+	fmt.Println("Block Excess Blob Gas:", r.BlockExcessBlobGas)
+	fmt.Println("Block Blob Gas Used:", r.BlockBlobGasUsed, fmt.Sprintf("(%d blobs)", r.BlockBlobGasUsed/params.BlobTxBlobGasPerBlob))
+	if r.HeaderBlobBaseFee != nil {
+		fmt.Println("Block Blob Base Fee:", r.HeaderBlobBaseFee, "wei")
+	}
+	fmt.Println("Blob Gas Used:", r.BlobGasUsed)
+	fmt.Println("Blob Gas Price:", r.BlobGasPrice, "wei")
+	fmt.Println("Blob Fee Paid:", weiString(r.BlobFee))
+	fmt.Println("Execution Gas Used:", r.GasUsed)
+	fmt.Println("Effective Gas Price:", r.EffectiveGasPrice, "wei")
+	fmt.Println("Execution Fee Paid:", weiString(r.ExecutionFee))
+	if r.BlobFee != nil && r.ExecutionFee != nil {
+		fmt.Println("Total Fee Paid:", weiString(new(big.Int).Add(r.BlobFee, r.ExecutionFee)))
+	}
+	fmt.Println("BlobFeeCap:", r.BlobFeeCap, "wei", "(max blob fee", weiString(r.MaxBlobFee)+")")
+	if r.BlobFeeMargin != nil {
+		fmt.Printf("BlobFeeCap headroom: %s wei per blob gas (%.1fx the price paid)\n", r.BlobFeeMargin, ratio(r.BlobFeeCap, r.BlobGasPrice))
+	}
+}
+
+// weiString formats an amount in wei together with its value in ether.
+func weiString(wei *big.Int) string {
+	if wei == nil {
+		return "unknown"
+	}
+	eth := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether))
+	return fmt.Sprintf("%s wei (%s ETH)", wei, eth.Text('f', 9))
+}
+
+func ratio(a, b *big.Int) float64 {
+	if b.Sign() == 0 {
+		return 0
+	}
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(a), new(big.Float).SetInt(b)).Float64()
+	return f
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// maxBlobsPerTxOsaka is the per-transaction blob limit introduced with PeerDAS
+	// (EIP-7594). Before Osaka a transaction may use the whole block allowance.
+	maxBlobsPerTxOsaka = 6
+
+	// blobSidecarVersion0 sidecars carry one KZG proof per blob.
+	blobSidecarVersion0 = 0
+	// blobSidecarVersion1 sidecars carry kzg4844.CellProofsPerBlob cell proofs per
+	// blob, as required by PeerDAS (EIP-7594) from Osaka on.
+	blobSidecarVersion1 = 1
+)
+
+// knownChainConfigs maps the chain IDs we send blobs to onto their fork schedule.
+var knownChainConfigs = map[uint64]*params.ChainConfig{
+	params.MainnetChainConfig.ChainID.Uint64(): params.MainnetChainConfig,
+	params.SepoliaChainConfig.ChainID.Uint64(): params.SepoliaChainConfig,
+	params.HoleskyChainConfig.ChainID.Uint64(): params.HoleskyChainConfig,
+	params.HoodiChainConfig.ChainID.Uint64():   params.HoodiChainConfig,
+}
+
+// chainConfigFor returns the fork schedule of a known blob-enabled network.
+func chainConfigFor(chainID uint64) (*params.ChainConfig, error) {
+	cfg, ok := knownChainConfigs[chainID]
+	if !ok {
+		return nil, fmt.Errorf("no blob schedule known for chain %d", chainID)
+	}
+	return cfg, nil
+}
+
+// maxBlobsPerTx returns how many blobs a transaction accepted by the pool at
+// head may carry. geth's pool validates against head, not the next block.
+func maxBlobsPerTx(cfg *params.ChainConfig, head *types.Header) int {
+	limit := eip4844.MaxBlobsPerBlock(cfg, head.Time)
+	if cfg.IsOsaka(head.Number, head.Time) && limit > maxBlobsPerTxOsaka {
+		limit = maxBlobsPerTxOsaka
+	}
+	return limit
+}
+
+// sidecarVersionAt returns the sidecar version accepted by the pool at head.
+func sidecarVersionAt(cfg *params.ChainConfig, head *types.Header) byte {
+	if cfg.IsOsaka(head.Number, head.Time) {
+		return blobSidecarVersion1
+	}
+	return blobSidecarVersion0
+}
+
+// buildSidecar computes the commitment, proofs and versioned hash of every blob
+// in parallel and assembles a sidecar of the given version together with the
+// matching BlobHashes.
+func buildSidecar(blobs []kzg4844.Blob, maxBlobs int, version byte) (*types.BlobTxSidecar, []common.Hash, error) {
+	if len(blobs) == 0 {
+		return nil, nil, errors.New("a blob transaction needs at least one blob")
+	}
+	if len(blobs) > maxBlobs {
+		return nil, nil, fmt.Errorf("%d blobs exceed the per-transaction maximum of %d", len(blobs), maxBlobs)
+	}
+
+	if version != blobSidecarVersion0 && version != blobSidecarVersion1 {
+		return nil, nil, fmt.Errorf("unsupported sidecar version %d", version)
+	}
+	kzg, err := loadKZG()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		commitments = make([]kzg4844.Commitment, len(blobs))
+		proofs      = make([][]kzg4844.Proof, len(blobs))
+		hashes      = make([]common.Hash, len(blobs))
+		errs        = make([]error, len(blobs))
+		wg          sync.WaitGroup
+	)
+	for i := range blobs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			commitment, err := kzg.BlobToCommitment(&blobs[i])
+			if err != nil {
+				errs[i] = fmt.Errorf("blob %d: failed to compute commitment: %w", i, err)
+				return
+			}
+			blobProofs, err := computeProofs(kzg, &blobs[i], commitment, version)
+			if err != nil {
+				errs[i] = fmt.Errorf("blob %d: %w", i, err)
+				return
+			}
+			commitments[i] = commitment
+			proofs[i] = blobProofs
+			hashes[i] = kzg4844.CalcBlobHashV1(sha256.New(), &commitment)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+	sidecar := &types.BlobTxSidecar{
+		Version:     version,
+		Blobs:       blobs,
+		Commitments: commitments,
+	}
+	for _, blobProofs := range proofs {
+		sidecar.Proofs = append(sidecar.Proofs, blobProofs...)
+	}
+	return sidecar, hashes, nil
+}
+
+// computeProofs returns the single blob proof for version 0 sidecars, or the
+// cell proofs of the extended blob for version 1.
+func computeProofs(kzg kzgBackend, blob *kzg4844.Blob, commitment kzg4844.Commitment, version byte) ([]kzg4844.Proof, error) {
+	if version == blobSidecarVersion1 {
+		proofs, err := kzg.ComputeCellProofs(blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute cell proofs: %w", err)
+		}
+		return proofs, nil
+	}
+	proof, err := kzg.ComputeBlobProof(blob, commitment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute proof: %w", err)
+	}
+	return []kzg4844.Proof{proof}, nil
+}
+
+// convertSidecar re-expresses sidecar in the requested version, recomputing the
+// proofs. Blobs and commitments are shared with the original.
+func convertSidecar(sidecar *types.BlobTxSidecar, version byte) (*types.BlobTxSidecar, error) {
+	if sidecar.Version == version {
+		return sidecar, nil
+	}
+	if version != blobSidecarVersion0 && version != blobSidecarVersion1 {
+		return nil, fmt.Errorf("unsupported sidecar version %d", version)
+	}
+	if len(sidecar.Blobs) != len(sidecar.Commitments) {
+		return nil, fmt.Errorf("sidecar has %d blobs and %d commitments", len(sidecar.Blobs), len(sidecar.Commitments))
+	}
+	kzg, err := loadKZG()
+	if err != nil {
+		return nil, err
+	}
+
+	converted := &types.BlobTxSidecar{
+		Version:     version,
+		Blobs:       sidecar.Blobs,
+		Commitments: sidecar.Commitments,
+	}
+	for i := range sidecar.Blobs {
+		proofs, err := computeProofs(kzg, &sidecar.Blobs[i], sidecar.Commitments[i], version)
+		if err != nil {
+			return nil, fmt.Errorf("blob %d: %w", i, err)
+		}
+		converted.Proofs = append(converted.Proofs, proofs...)
+	}
+	return converted, nil
+}
+
+// verifySidecar checks that the sidecar is internally consistent and matches
+// the versioned hashes the transaction commits to.
+func verifySidecar(sidecar *types.BlobTxSidecar, hashes []common.Hash) error {
+	proofsPerBlob := 1
+	if sidecar.Version == blobSidecarVersion1 {
+		proofsPerBlob = kzg4844.CellProofsPerBlob
+	}
+	if len(sidecar.Blobs) != len(sidecar.Commitments) || len(sidecar.Blobs)*proofsPerBlob != len(sidecar.Proofs) {
+		return fmt.Errorf("version %d sidecar has %d blobs, %d commitments and %d proofs", sidecar.Version, len(sidecar.Blobs), len(sidecar.Commitments), len(sidecar.Proofs))
+	}
+	if err := sidecar.ValidateBlobCommitmentHashes(hashes); err != nil {
+		return err
+	}
+	kzg, err := loadKZG()
+	if err != nil {
+		return err
+	}
+
+	if sidecar.Version == blobSidecarVersion1 {
+		if err := kzg.VerifyCellProofs(sidecar.Blobs, sidecar.Commitments, sidecar.Proofs); err != nil {
+			return fmt.Errorf("invalid cell proofs: %w", err)
+		}
+		return nil
+	}
+	for i := range sidecar.Blobs {
+		if err := kzg.VerifyBlobProof(&sidecar.Blobs[i], sidecar.Commitments[i], sidecar.Proofs[i]); err != nil {
+			return fmt.Errorf("blob %d: invalid proof: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// The fork is picked from the head's timestamp, like the pool does: a head
+// just before Osaka still gets the Prague rules, even though the next block
+// is past the fork.
+func TestForkAtHead(t *testing.T) {
+	const osaka = 1_000
+	cfg := *params.MergedTestChainConfig
+	osakaTime := uint64(osaka)
+	cfg.OsakaTime = &osakaTime
+	prague := params.DefaultPragueBlobConfig.Max
+
+	tests := []struct {
+		time     uint64
+		maxBlobs int
+		version  byte
+	}{
+		{osaka - 12, prague, blobSidecarVersion0},
+		{osaka - 1, prague, blobSidecarVersion0},
+		{osaka, maxBlobsPerTxOsaka, blobSidecarVersion1},
+		{osaka + 12, maxBlobsPerTxOsaka, blobSidecarVersion1},
+	}
+	for _, tt := range tests {
+		head := &types.Header{Number: big.NewInt(100), Time: tt.time}
+		if got := maxBlobsPerTx(&cfg, head); got != tt.maxBlobs {
+			t.Errorf("time %d: max blobs %d, want %d", tt.time, got, tt.maxBlobs)
+		}
+		if got := sidecarVersionAt(&cfg, head); got != tt.version {
+			t.Errorf("time %d: sidecar version %d, want %d", tt.time, got, tt.version)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 	"transactiontypes/account"
+	"transactiontypes/sigverify"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -122,4 +123,13 @@ func main() {
 	}
 
 	fmt.Printf("Signature verified successfully for owner: %s\n", acc2Addr.Hex())
+
+	// 7) Verify off-chain as well. This also covers owners that are contract
+	// wallets or EIP-7702 delegated EOAs (ERC-1271) and counterfactual
+	// accounts (ERC-6492).
+	valid, err := sigverify.NewVerifier(client).VerifySignature(ctx, *acc2Addr, common.BytesToHash(digest), sig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Off-chain signature valid?", valid)
 }
@@ -3,12 +3,19 @@ package main
 import (
 	"context"
 	"crypto/ecdsa"
+	"flag"
 	"fmt"
 	"log"
 	"math/big"
 	"strings"
 	"time"
 	"transactiontypes/account"
+	"transactiontypes/builder"
+	"transactiontypes/journal"
+	"transactiontypes/logdecode"
+	"transactiontypes/monitor"
+	"transactiontypes/policy"
+	"transactiontypes/replay"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -26,6 +33,9 @@ const (
 )
 
 func main() {
+	allowAnyChain := flag.Bool("allow-any-chain", false, "sign even if an authorization is valid on every chain (chain ID 0)")
+	flag.Parse()
+
 	ctx := context.Background()
 	client, err := ethclient.Dial(NodeRPCURL)
 	if err != nil {
@@ -135,14 +145,16 @@ func main() {
 	s1 := new(big.Int).SetBytes(sig1[32:64])
 	v1 := uint8(sig1[64])
 
-	// Build EIP-7702 TxWithDelegation
-	delegation := types.SetCodeTx{
-		ChainID:   uint256.NewInt(AmoyChainID),
+	// Describe the EIP-7702 TxWithDelegation
+	req := &builder.Request{
+		Type:      types.SetCodeTxType,
+		ChainID:   big.NewInt(AmoyChainID),
+		From:      *acc2Addr,
+		To:        &to,
 		Nonce:     baseNonce2,
-		GasTipCap: uint256.MustFromBig(gasTipCap),
-		GasFeeCap: uint256.MustFromBig(gasFeeCap),
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
 		Gas:       120000,
-		To:        to,
 		Data:      data,
 		AuthList: []types.SetCodeAuthorization{
 			{
@@ -164,25 +176,66 @@ func main() {
 		},
 	}
 
-	fullTx := types.NewTx(&delegation)
-	signedTx, err := types.SignTx(fullTx, types.LatestSignerForChainID(big.NewInt(AmoyChainID)), acc2Priv)
+	// An authorization with chain ID 0 lets anyone delegate the account on every
+	// chain, refuse to sign one unless explicitly asked to
+	highRisk := false
+	for _, finding := range replay.CheckAuthorizations(req.AuthList, big.NewInt(AmoyChainID)) {
+		fmt.Println(finding)
+		highRisk = highRisk || finding.Risk == replay.RiskHigh
+	}
+	if highRisk && !*allowAnyChain {
+		log.Fatal("Refusing to sign chain-agnostic authorizations, pass -allow-any-chain to override")
+	}
+
+	spending, err := policy.Default()
+	if err != nil {
+		log.Fatal("Failed to load spending policy:", err)
+	}
+	if err := spending.Check(req); err != nil {
+		log.Fatal(err)
+	}
+
+	fullTx, err := req.Build()
+	if err != nil {
+		log.Fatal("Failed to build transaction:", err)
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(AmoyChainID))
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	if err := jrnl.RecordBuilt(fullTx, *acc2Addr, signer); err != nil {
+		log.Fatal("Failed to journal transaction:", err)
+	}
+	signedTx, err := types.SignTx(fullTx, signer, acc2Priv)
 	if err != nil {
 		log.Fatal("Signing failed:", err)
 	}
 
-	err = client.SendTransaction(ctx, signedTx)
+	err = jrnl.Broadcast(ctx, client, NodeRPCURL, signedTx, *acc2Addr)
 	if err != nil {
 		log.Fatal("Tx failed:", err)
 	}
 
 	fmt.Println("EIP-7702 Tx sent:", signedTx.Hash().Hex())
 
-	time.Sleep(10 * time.Second)
-	receipt, err := client.TransactionReceipt(ctx, signedTx.Hash())
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	mined, err := monitor.Wait(waitCtx, client, signedTx, *acc2Addr, monitor.StatusIncluded)
 	if err != nil {
-		fmt.Println("Waiting...")
-	} else {
-		fmt.Println("Tx mined in block", receipt.BlockNumber)
+		fmt.Println("Tx not mined yet:", err)
+		return
+	}
+	receipt := mined.Receipt
+	fmt.Println("Tx mined in block", receipt.BlockNumber)
+
+	// PingStart and PingSuccess come from the invoker, Pinged from the module
+	// each delegated account points to
+	registry := logdecode.Default()
+	registry.Add("MultiDelegationInvoker", parsedAbi, to)
+	for _, event := range registry.DecodeReceipt(receipt) {
+		fmt.Println(event)
 	}
 }
 
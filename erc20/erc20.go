@@ -0,0 +1,220 @@
+// Package erc20 encodes ERC-20 calls and decodes their events with the
+// contract ABI instead of hand-written calldata.
+package erc20
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// abiJSON is the subset of the ERC-20 interface used here.
+const abiJSON = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"transferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+	{"type":"event","name":"Approval","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+// ABI is the parsed ERC-20 interface.
+var ABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+var (
+	// TransferTopic is the topic of Transfer(address,address,uint256).
+	TransferTopic = ABI.Events["Transfer"].ID
+	// ApprovalTopic is the topic of Approval(address,address,uint256).
+	ApprovalTopic = ABI.Events["Approval"].ID
+)
+
+// PackTransfer returns the calldata of transfer(to, value).
+func PackTransfer(to common.Address, value *big.Int) ([]byte, error) {
+	return ABI.Pack("transfer", to, value)
+}
+
+// PackApprove returns the calldata of approve(spender, value).
+func PackApprove(spender common.Address, value *big.Int) ([]byte, error) {
+	return ABI.Pack("approve", spender, value)
+}
+
+// PackTransferFrom returns the calldata of transferFrom(from, to, value).
+func PackTransferFrom(from, to common.Address, value *big.Int) ([]byte, error) {
+	return ABI.Pack("transferFrom", from, to, value)
+}
+
+// Token is an ERC-20 contract together with its metadata.
+type Token struct {
+	Address  common.Address
+	Symbol   string
+	Decimals uint8
+
+	client *ethclient.Client
+}
+
+// NewToken reads decimals() and symbol() from the token contract.
+func NewToken(ctx context.Context, client *ethclient.Client, address common.Address) (*Token, error) {
+	t := &Token{Address: address, client: client}
+
+	code, err := client.CodeAt(ctx, address, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch code: %w", err)
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("no contract deployed at %s", address.Hex())
+	}
+
+	values, err := t.call(ctx, "decimals")
+	if err != nil {
+		return nil, fmt.Errorf("decimals(): %w", err)
+	}
+	t.Decimals = values[0].(uint8)
+
+	res, err := t.rawCall(ctx, "symbol")
+	if err != nil {
+		return nil, fmt.Errorf("symbol(): %w", err)
+	}
+	if t.Symbol, err = unpackSymbol(res); err != nil {
+		return nil, fmt.Errorf("symbol(): %w", err)
+	}
+	return t, nil
+}
+
+// BalanceOf returns the token balance of owner in base units.
+func (t *Token) BalanceOf(ctx context.Context, owner common.Address) (*big.Int, error) {
+	values, err := t.call(ctx, "balanceOf", owner)
+	if err != nil {
+		return nil, fmt.Errorf("balanceOf(): %w", err)
+	}
+	return values[0].(*big.Int), nil
+}
+
+// Allowance returns how much spender may still move on behalf of owner.
+func (t *Token) Allowance(ctx context.Context, owner, spender common.Address) (*big.Int, error) {
+	values, err := t.call(ctx, "allowance", owner, spender)
+	if err != nil {
+		return nil, fmt.Errorf("allowance(): %w", err)
+	}
+	return values[0].(*big.Int), nil
+}
+
+// ParseAmount converts a human amount such as "12.5" or "12.5 USDC" into base
+// units. A symbol, if present, has to match the token's.
+func (t *Token) ParseAmount(s string) (*big.Int, error) {
+	fields := strings.Fields(s)
+	switch {
+	case len(fields) == 2:
+		if !strings.EqualFold(fields[1], t.Symbol) {
+			return nil, fmt.Errorf("amount is in %s, token is %s", fields[1], t.Symbol)
+		}
+	case len(fields) != 1:
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return ParseUnits(fields[0], t.Decimals)
+}
+
+// FormatAmount renders a base unit amount with the token's decimals and symbol.
+func (t *Token) FormatAmount(v *big.Int) string {
+	return FormatUnits(v, t.Decimals) + " " + t.Symbol
+}
+
+// CheckBalance fails if owner holds less than value.
+func (t *Token) CheckBalance(ctx context.Context, owner common.Address, value *big.Int) error {
+	balance, err := t.BalanceOf(ctx, owner)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(value) < 0 {
+		return fmt.Errorf("%s holds %s, need %s", owner.Hex(), t.FormatAmount(balance), t.FormatAmount(value))
+	}
+	return nil
+}
+
+// CheckAllowance fails if spender may move less than value for owner.
+func (t *Token) CheckAllowance(ctx context.Context, owner, spender common.Address, value *big.Int) error {
+	allowance, err := t.Allowance(ctx, owner, spender)
+	if err != nil {
+		return err
+	}
+	if allowance.Cmp(value) < 0 {
+		return fmt.Errorf("%s allows %s to spend %s, need %s", owner.Hex(), spender.Hex(), t.FormatAmount(allowance), t.FormatAmount(value))
+	}
+	return nil
+}
+
+func (t *Token) rawCall(ctx context.Context, method string, args ...interface{}) ([]byte, error) {
+	data, err := ABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return t.client.CallContract(ctx, ethereum.CallMsg{To: &t.Address, Data: data}, nil)
+}
+
+func (t *Token) call(ctx context.Context, method string, args ...interface{}) ([]interface{}, error) {
+	res, err := t.rawCall(ctx, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ABI.Unpack(method, res)
+}
+
+// unpackSymbol accepts symbols returned as string, or as bytes32 like some
+// early tokens (MKR, SAI) do.
+func unpackSymbol(res []byte) (string, error) {
+	if values, err := ABI.Unpack("symbol", res); err == nil {
+		return values[0].(string), nil
+	}
+	if len(res) == 32 {
+		return strings.TrimRight(string(res), "\x00"), nil
+	}
+	return "", errors.New("cannot decode symbol")
+}
+
+// Transfer is a decoded Transfer event.
+type Transfer struct {
+	Token common.Address
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+}
+
+// DecodeTransfers returns the Transfer events among logs. ERC-721 transfers
+// share the topic but index the token ID, they are skipped.
+func DecodeTransfers(logs []*types.Log) ([]Transfer, error) {
+	var transfers []Transfer
+	for _, l := range logs {
+		if len(l.Topics) != 3 || l.Topics[0] != TransferTopic {
+			continue
+		}
+		values, err := ABI.Unpack("Transfer", l.Data)
+		if err != nil {
+			return nil, fmt.Errorf("log %d: %w", l.Index, err)
+		}
+		transfers = append(transfers, Transfer{
+			Token: l.Address,
+			From:  common.BytesToAddress(l.Topics[1].Bytes()),
+			To:    common.BytesToAddress(l.Topics[2].Bytes()),
+			Value: values[0].(*big.Int),
+		})
+	}
+	return transfers, nil
+}
@@ -0,0 +1,44 @@
+package erc20
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ParseUnits converts a decimal string such as "12.5" into base units of a
+// token with the given decimals. More fractional digits than decimals is an
+// error rather than silently rounding.
+func ParseUnits(s string, decimals uint8) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > int(decimals) {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", s, decimals)
+	}
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+	v, _ := new(big.Int).SetString(digits, 10)
+	return v, nil
+}
+
+// FormatUnits renders base units as a decimal string, trimming trailing zeros.
+func FormatUnits(v *big.Int, decimals uint8) string {
+	s := new(big.Int).Abs(v).String()
+	if len(s) <= int(decimals) {
+		s = strings.Repeat("0", int(decimals)-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-int(decimals)], strings.TrimRight(s[len(s)-int(decimals):], "0")
+	if v.Sign() < 0 {
+		whole = "-" + whole
+	}
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
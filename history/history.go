@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"transactiontypes/journal"
+	"transactiontypes/monitor"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const usage = `Usage:
+  history [flags] list
+  history [flags] show <hash>
+  history [flags] reconcile
+  history [flags] watch [-ws url] [-confirmations n] [-rebroadcast] [hash...]
+  history [flags] rebroadcast [hash...]
+
+The journal is written by every example that sends a transaction, from the
+moment it is built. A transaction listed as built was never signed. reconcile
+asks the node each unfinalized transaction was sent through for its status
+once, watch follows them until they are finalized or replaced. watch subscribes
+to new blocks when the node is reached over websocket (-ws), and polls
+otherwise.
+
+Transactions reorged out of their block, or dropped from the pool, can be sent
+again from their journaled bytes with rebroadcast (all of them by default), or
+automatically while watching with -rebroadcast.
+
+Flags:`
+
+func main() {
+	path := flag.String("journal", journal.DefaultPath(), "journal file, $TX_JOURNAL overrides the default")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	jrnl, err := journal.Open(*path)
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+
+	switch args[0] {
+	case "list":
+		list(jrnl)
+	case "show":
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		show(jrnl, common.HexToHash(args[1]))
+	case "reconcile":
+		reconcile(jrnl)
+	case "watch":
+		watch(jrnl, args[1:])
+	case "rebroadcast":
+		rebroadcast(jrnl, args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func list(jrnl *journal.Journal) {
+	records, err := jrnl.Records()
+	if err != nil {
+		log.Fatal("Failed to read journal:", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tHASH\tTYPE\tFROM\tNONCE\tSTATUS\tBLOCK")
+	for _, r := range records {
+		from, block := "-", "-"
+		if r.From != nil {
+			from = r.From.Hex()
+		}
+		if r.BlockNumber != nil {
+			block = r.BlockNumber.ToInt().String()
+		}
+		status := string(r.Status)
+		if r.Finality != "" {
+			status += " (" + string(r.Finality) + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\t%s\n",
+			r.FirstSeen.Local().Format("2006-01-02 15:04:05"), r.Hash.Hex(), uint64(deref(r.Type)),
+			from, uint64(deref(r.Nonce)), status, block)
+	}
+	w.Flush()
+}
+
+func show(jrnl *journal.Journal, hash common.Hash) {
+	records, err := jrnl.Records()
+	if err != nil {
+		log.Fatal("Failed to read journal:", err)
+	}
+	for _, r := range records {
+		if r.Hash != hash {
+			continue
+		}
+		out, err := json.MarshalIndent(r.Entry, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(out))
+		fmt.Println("First seen:", r.FirstSeen.Local())
+		return
+	}
+	log.Fatalf("Transaction %s is not in %s", hash.Hex(), jrnl.Path())
+}
+
+func reconcile(jrnl *journal.Journal) {
+	records, err := jrnl.Records()
+	if err != nil {
+		log.Fatal("Failed to read journal:", err)
+	}
+	// Each transaction is checked against the node it was sent through
+	var networks []string
+	seen := make(map[string]bool)
+	for _, r := range records {
+		if !r.Final() && r.Network != "" && !seen[r.Network] {
+			seen[r.Network] = true
+			networks = append(networks, r.Network)
+		}
+	}
+	if len(networks) == 0 {
+		fmt.Println("Everything is final.")
+		return
+	}
+
+	ctx := context.Background()
+	for _, network := range networks {
+		client, err := ethclient.Dial(network)
+		if err != nil {
+			log.Printf("Failed to connect to %s: %v", network, err)
+			continue
+		}
+		changes, err := jrnl.Reconcile(ctx, client, network)
+		client.Close()
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		if err != nil {
+			log.Printf("Failed to reconcile %s: %v", network, err)
+			continue
+		}
+		fmt.Printf("%s: %d updated\n", network, len(changes))
+	}
+}
+
+func watch(jrnl *journal.Journal, args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	ws := fs.String("ws", "", "websocket URL to watch through instead of the recorded RPC URLs")
+	confirmations := fs.Uint64("confirmations", 12, "blocks until a transaction counts as confirmed")
+	resend := fs.Bool("rebroadcast", false, "send reorged transactions again from the journal")
+	fs.Parse(args)
+
+	records, err := jrnl.Records()
+	if err != nil {
+		log.Fatal("Failed to read journal:", err)
+	}
+	only := make(map[common.Hash]bool)
+	for _, arg := range fs.Args() {
+		only[common.HexToHash(arg)] = true
+	}
+
+	// One monitor per node, transactions are followed where they were sent
+	byNode := make(map[string][]*journal.Record)
+	for _, r := range records {
+		switch {
+		case len(only) > 0 && !only[r.Hash]:
+			continue
+		case len(only) == 0 && r.Final():
+			continue
+		case r.From == nil || r.Nonce == nil:
+			continue
+		case r.Network == "" && *ws == "":
+			// Signed by signerd, sent by someone else
+			continue
+		}
+		node := r.Network
+		if *ws != "" {
+			node = *ws
+		}
+		byNode[node] = append(byNode[node], r)
+	}
+	if len(byNode) == 0 {
+		fmt.Println("Nothing to watch.")
+		return
+	}
+
+	ctx := context.Background()
+	events := make(chan monitor.Event)
+	clients := make(map[common.Hash]*ethclient.Client)
+	var wg sync.WaitGroup
+	for node, list := range byNode {
+		client, err := ethclient.Dial(node)
+		if err != nil {
+			log.Fatalf("Failed to connect to %s: %v", node, err)
+		}
+		defer client.Close()
+		m := monitor.New(client, *confirmations)
+		for _, r := range list {
+			m.Watch(r.Hash, *r.From, uint64(*r.Nonce))
+			clients[r.Hash] = client
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Run(ctx, events); err != nil {
+				log.Printf("Watching through %s failed: %v", node, err)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	for e := range events {
+		fmt.Println(e)
+		if update, ok := entryFor(e); ok {
+			if err := jrnl.Append(update); err != nil {
+				log.Fatal("Failed to record status:", err)
+			}
+		}
+		if e.Status == monitor.StatusReorged && *resend {
+			r, err := record(jrnl, e.Hash)
+			if err == nil {
+				err = jrnl.Rebroadcast(ctx, clients[e.Hash], r)
+			}
+			if err != nil {
+				log.Printf("Failed to rebroadcast %s: %v", e.Hash.Hex(), err)
+				continue
+			}
+			fmt.Println(e.Hash.Hex(), "rebroadcast")
+		}
+	}
+}
+
+func rebroadcast(jrnl *journal.Journal, args []string) {
+	records, err := jrnl.Records()
+	if err != nil {
+		log.Fatal("Failed to read journal:", err)
+	}
+	only := make(map[common.Hash]bool)
+	for _, arg := range args {
+		only[common.HexToHash(arg)] = true
+	}
+
+	ctx := context.Background()
+	clients := make(map[string]*ethclient.Client)
+	sent := 0
+	for _, r := range records {
+		if len(only) > 0 && !only[r.Hash] {
+			continue
+		}
+		if len(only) == 0 && r.Status != journal.StatusReorged && r.Status != journal.StatusDropped {
+			continue
+		}
+		if r.Network == "" {
+			log.Printf("Skipping %s, it was not sent through a known node", r.Hash.Hex())
+			continue
+		}
+		client, ok := clients[r.Network]
+		if !ok {
+			if client, err = ethclient.Dial(r.Network); err != nil {
+				log.Fatalf("Failed to connect to %s: %v", r.Network, err)
+			}
+			defer client.Close()
+			clients[r.Network] = client
+		}
+		if err := jrnl.Rebroadcast(ctx, client, r); err != nil {
+			log.Printf("Failed to rebroadcast %s: %v", r.Hash.Hex(), err)
+			continue
+		}
+		fmt.Println(r.Hash.Hex(), "rebroadcast through", r.Network)
+		sent++
+	}
+	fmt.Printf("%d rebroadcast\n", sent)
+}
+
+// record returns the current state of one journaled transaction.
+func record(jrnl *journal.Journal, hash common.Hash) (*journal.Record, error) {
+	records, err := jrnl.Records()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Hash == hash {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %s is not in %s", hash.Hex(), jrnl.Path())
+}
+
+// entryFor turns a monitor event into a journal update, for the events the
+// journal has a status for.
+func entryFor(e monitor.Event) (journal.Entry, bool) {
+	update := journal.Entry{Hash: e.Hash}
+	switch e.Status {
+	case monitor.StatusPending:
+		update.Status = journal.StatusPending
+	case monitor.StatusIncluded, monitor.StatusSafe, monitor.StatusFinalized:
+		update.Status = journal.StatusIncluded
+		if e.Receipt.Status != types.ReceiptStatusSuccessful {
+			update.Status = journal.StatusReverted
+		}
+		update.BlockNumber = (*hexutil.Big)(e.Receipt.BlockNumber)
+		update.BlockHash = &e.Receipt.BlockHash
+		update.GasUsed = (*hexutil.Uint64)(&e.Receipt.GasUsed)
+		switch e.Status {
+		case monitor.StatusSafe:
+			update.Finality = journal.FinalitySafe
+		case monitor.StatusFinalized:
+			update.Finality = journal.FinalityFinalized
+		}
+	case monitor.StatusReorged:
+		update.Status = journal.StatusReorged
+		update.Error = fmt.Sprintf("reorged out of block %d", e.BlockNumber)
+	case monitor.StatusReplaced:
+		update.Status = journal.StatusReplaced
+		if e.ReplacedBy != (common.Hash{}) {
+			update.Error = "replaced by " + e.ReplacedBy.Hex()
+		}
+	default:
+		return update, false
+	}
+	return update, true
+}
+
+func deref(v *hexutil.Uint64) hexutil.Uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
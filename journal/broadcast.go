@@ -0,0 +1,86 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Broadcast records tx as signed, sends it through client and records whether
+// the node accepted it. network names the node, usually its RPC URL, so the
+// reconciler can find it again. Nothing is sent if recording fails.
+func (j *Journal) Broadcast(ctx context.Context, client *ethclient.Client, network string, tx *types.Transaction, from common.Address) error {
+	entry, err := NewEntry(network, tx, from)
+	if err != nil {
+		return err
+	}
+	// Blobs are too large for a journal line, keep the network encoding in a
+	// file next to it so the transaction can be sent again
+	if tx.BlobTxSidecar() != nil {
+		if entry.Sidecar, err = j.storeSidecar(tx); err != nil {
+			return err
+		}
+	}
+	if err := j.Append(entry); err != nil {
+		return err
+	}
+
+	return j.send(ctx, client, tx, StatusRejected)
+}
+
+// Rebroadcast sends a recorded transaction again, from its journaled raw
+// bytes and sidecar, e.g. after it was reorged out or dropped from the pool.
+// A refusal is journaled as an error without changing the status.
+func (j *Journal) Rebroadcast(ctx context.Context, client *ethclient.Client, r *Record) error {
+	if !r.Pending() {
+		return fmt.Errorf("transaction %s is %s, not waiting for a block", r.Hash.Hex(), r.Status)
+	}
+	tx, err := r.Transaction()
+	if err != nil {
+		return err
+	}
+	if tx.Hash() != r.Hash {
+		return fmt.Errorf("journaled bytes hash to %s, not %s", tx.Hash().Hex(), r.Hash.Hex())
+	}
+	return j.send(ctx, client, tx, r.Status)
+}
+
+// send sends tx and journals it as pending, or as failed with the error.
+func (j *Journal) send(ctx context.Context, client *ethclient.Client, tx *types.Transaction, failed Status) error {
+	sendErr := client.SendTransaction(ctx, tx)
+	if sendErr != nil && strings.Contains(sendErr.Error(), txpool.ErrAlreadyKnown.Error()) {
+		// Sent before, the node still has it
+		sendErr = nil
+	}
+	update := Entry{Hash: tx.Hash(), Status: StatusPending}
+	if sendErr != nil {
+		update.Status, update.Error = failed, sendErr.Error()
+	}
+	if err := j.Append(update); err != nil {
+		return fmt.Errorf("sent, but failed to record it: %w", err)
+	}
+	return sendErr
+}
+
+func (j *Journal) storeSidecar(tx *types.Transaction) (string, error) {
+	network, err := tx.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(filepath.Dir(j.path), "sidecars")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create sidecar directory: %w", err)
+	}
+	path := filepath.Join(dir, tx.Hash().Hex()+".tx")
+	if err := os.WriteFile(path, network, 0o600); err != nil {
+		return "", fmt.Errorf("failed to store sidecar: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,390 @@
+// Package journal keeps an append-only record of the transactions the tools
+// in this repository send, in a JSON lines file that survives the process.
+//
+// Every change is a new line; nothing is rewritten. The state of a
+// transaction is the merge of all lines with its hash, see Records.
+//
+// A transaction is first recorded when it is built, before signing, under its
+// signing hash. Once signed it is recorded under its transaction hash, and
+// the built record is left out of Records. Built records that remain are
+// transactions that were never signed.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+	"transactiontypes/builder"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Status is the lifecycle stage of a transaction.
+type Status string
+
+const (
+	StatusBuilt    Status = "built"    // built and checked, not signed
+	StatusSigned   Status = "signed"   // signed, not (successfully) sent
+	StatusPending  Status = "pending"  // accepted by the node
+	StatusIncluded Status = "included" // mined and succeeded
+	StatusReverted Status = "reverted" // mined and failed
+	StatusReplaced Status = "replaced" // another transaction used the nonce
+	StatusDropped  Status = "dropped"  // unknown to the node, nonce still free
+	StatusRejected Status = "rejected" // the node refused it
+	StatusReorged  Status = "reorged"  // was mined, its block left the chain
+)
+
+// Finality is how settled the block of a mined transaction is, using the
+// node's "safe" and "finalized" block tags.
+type Finality string
+
+const (
+	FinalitySafe      Finality = "safe"      // unlikely to be reorged
+	FinalityFinalized Finality = "finalized" // can't be reorged without slashing
+)
+
+// Entry is one line of the journal. Only Time, Hash and Status are always
+// set; status updates carry just the fields that changed.
+type Entry struct {
+	Time   time.Time   `json:"time"`
+	Hash   common.Hash `json:"hash"`
+	Status Status      `json:"status"`
+
+	// Set when the transaction is first recorded
+	Network     string          `json:"network,omitempty"` // RPC URL it was sent through
+	ChainID     *hexutil.Big    `json:"chainId,omitempty"`
+	Type        *hexutil.Uint64 `json:"type,omitempty"`
+	From        *common.Address `json:"from,omitempty"`
+	To          *common.Address `json:"to,omitempty"`
+	Nonce       *hexutil.Uint64 `json:"nonce,omitempty"`
+	Value       *hexutil.Big    `json:"value,omitempty"`
+	Gas         *hexutil.Uint64 `json:"gas,omitempty"`
+	GasPrice    *hexutil.Big    `json:"gasPrice,omitempty"`
+	GasTipCap   *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	GasFeeCap   *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	BlobFeeCap  *hexutil.Big    `json:"maxFeePerBlobGas,omitempty"`
+	BlobHashes  []common.Hash   `json:"blobVersionedHashes,omitempty"`
+	SigningHash *common.Hash    `json:"signingHash,omitempty"` // hash of the built record
+	Raw         hexutil.Bytes   `json:"raw,omitempty"`         // canonical encoding, without blob sidecar
+	Sidecar     string          `json:"sidecar,omitempty"`     // file holding the network encoding with sidecar
+
+	// Set by status updates
+	BlockNumber *hexutil.Big    `json:"blockNumber,omitempty"`
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+	GasUsed     *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Finality    Finality        `json:"finality,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// Record is the merged state of one transaction.
+type Record struct {
+	Entry
+	FirstSeen time.Time
+}
+
+// Journal is a journal file. Each line is written with a single O_APPEND
+// write, so several tools can record into the same file.
+type Journal struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultPath is $TX_JOURNAL, or ~/.transactiontypes/journal.jsonl.
+func DefaultPath() string {
+	if path := os.Getenv("TX_JOURNAL"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".transactiontypes", "journal.jsonl")
+}
+
+// Open opens the journal at path, creating its directory if needed.
+func Open(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	return &Journal{path: path}, nil
+}
+
+// OpenDefault opens the journal at DefaultPath.
+func OpenDefault() (*Journal, error) {
+	return Open(DefaultPath())
+}
+
+// Path returns the journal file.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Append writes e as a new line and syncs it to disk. A zero Time is set to now.
+func (j *Journal) Append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return f.Close()
+}
+
+// Entries reads all lines in order. A truncated last line, left by a crash
+// during a write, is skipped.
+func (j *Journal) Entries() ([]Entry, error) {
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		entries []Entry
+		bad     error
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if bad != nil {
+			// Only the last line may be broken
+			return nil, bad
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			bad = fmt.Errorf("journal line %d: %w", line, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Records merges the entries per transaction, oldest first.
+func (j *Journal) Records() ([]*Record, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return nil, err
+	}
+	byHash := make(map[common.Hash]*Record)
+	var records []*Record
+	for _, e := range entries {
+		r, ok := byHash[e.Hash]
+		if !ok {
+			r = &Record{Entry: e, FirstSeen: e.Time}
+			byHash[e.Hash] = r
+			records = append(records, r)
+			continue
+		}
+		r.merge(e)
+	}
+
+	// Drop built records that were signed since
+	signed := make(map[common.Hash]bool)
+	for _, r := range records {
+		if r.SigningHash != nil && r.Status != StatusBuilt {
+			signed[*r.SigningHash] = true
+		}
+	}
+	records = slices.DeleteFunc(records, func(r *Record) bool {
+		return r.Status == StatusBuilt && signed[r.Hash]
+	})
+	sort.SliceStable(records, func(a, b int) bool { return records[a].FirstSeen.Before(records[b].FirstSeen) })
+	return records, nil
+}
+
+// merge applies a later entry: status, time and finality always, other fields
+// if set. Block fields are cleared when a transaction leaves a block.
+func (r *Record) merge(e Entry) {
+	r.Time, r.Status, r.Finality, r.Error = e.Time, e.Status, e.Finality, e.Error
+	if !r.Mined() {
+		r.BlockNumber, r.BlockHash, r.GasUsed = nil, nil, nil
+	}
+
+	setBig := func(dst **hexutil.Big, src *hexutil.Big) {
+		if src != nil {
+			*dst = src
+		}
+	}
+	setU64 := func(dst **hexutil.Uint64, src *hexutil.Uint64) {
+		if src != nil {
+			*dst = src
+		}
+	}
+	if e.Network != "" {
+		r.Network = e.Network
+	}
+	if e.From != nil {
+		r.From = e.From
+	}
+	if e.To != nil {
+		r.To = e.To
+	}
+	if e.SigningHash != nil {
+		r.SigningHash = e.SigningHash
+	}
+	if len(e.Raw) > 0 {
+		r.Raw = e.Raw
+	}
+	if e.Sidecar != "" {
+		r.Sidecar = e.Sidecar
+	}
+	if len(e.BlobHashes) > 0 {
+		r.BlobHashes = e.BlobHashes
+	}
+	if e.BlockHash != nil {
+		r.BlockHash = e.BlockHash
+	}
+	setBig(&r.ChainID, e.ChainID)
+	setBig(&r.Value, e.Value)
+	setBig(&r.GasPrice, e.GasPrice)
+	setBig(&r.GasTipCap, e.GasTipCap)
+	setBig(&r.GasFeeCap, e.GasFeeCap)
+	setBig(&r.BlobFeeCap, e.BlobFeeCap)
+	setBig(&r.BlockNumber, e.BlockNumber)
+	setU64(&r.Type, e.Type)
+	setU64(&r.Nonce, e.Nonce)
+	setU64(&r.Gas, e.Gas)
+	setU64(&r.GasUsed, e.GasUsed)
+}
+
+// Pending reports whether the transaction is waiting for a block, or for a
+// rebroadcast.
+func (r *Record) Pending() bool {
+	switch r.Status {
+	case StatusSigned, StatusPending, StatusDropped, StatusReorged:
+		return true
+	}
+	return false
+}
+
+// Mined reports whether the transaction was in a block when last checked.
+func (r *Record) Mined() bool {
+	return r.Status == StatusIncluded || r.Status == StatusReverted
+}
+
+// Final reports whether the record can't change anymore: the transaction is
+// in a finalized block, or will never be. Built transactions are final, the
+// node doesn't know them until they are signed under another hash.
+func (r *Record) Final() bool {
+	switch r.Status {
+	case StatusBuilt, StatusReplaced, StatusRejected:
+		return true
+	}
+	return r.Mined() && r.Finality == FinalityFinalized
+}
+
+// Transaction decodes the recorded transaction, with its blob sidecar if one
+// was stored.
+func (r *Record) Transaction() (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if r.Sidecar != "" {
+		network, err := os.ReadFile(r.Sidecar)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sidecar: %w", err)
+		}
+		return tx, tx.UnmarshalBinary(network)
+	}
+	if len(r.Raw) == 0 {
+		return nil, errors.New("no raw transaction recorded")
+	}
+	return tx, tx.UnmarshalBinary(r.Raw)
+}
+
+// NewEntry describes a signed transaction sent through network.
+func NewEntry(network string, tx *types.Transaction, from common.Address) (Entry, error) {
+	e, err := describe(tx, from)
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Hash, e.Status, e.Network = tx.Hash(), StatusSigned, network
+	e.SigningHash = ptr(builder.SignerOf(tx).Hash(tx))
+	if tx.Protected() {
+		e.ChainID = (*hexutil.Big)(tx.ChainId())
+	}
+	return e, nil
+}
+
+// NewBuiltEntry describes an unsigned transaction that from is about to sign
+// with signer. It is recorded under the signing hash, see the package doc.
+func NewBuiltEntry(tx *types.Transaction, from common.Address, signer types.Signer) (Entry, error) {
+	e, err := describe(tx, from)
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Hash, e.Status = signer.Hash(tx), StatusBuilt
+	if chainID := signer.ChainID(); chainID != nil && chainID.Sign() > 0 {
+		e.ChainID = (*hexutil.Big)(chainID)
+	}
+	return e, nil
+}
+
+// RecordBuilt journals tx as built, before from signs it with signer.
+func (j *Journal) RecordBuilt(tx *types.Transaction, from common.Address, signer types.Signer) error {
+	e, err := NewBuiltEntry(tx, from, signer)
+	if err != nil {
+		return err
+	}
+	return j.Append(e)
+}
+
+// describe fills in the fields every first record of tx has.
+func describe(tx *types.Transaction, from common.Address) (Entry, error) {
+	raw, err := tx.WithoutBlobTxSidecar().MarshalBinary()
+	if err != nil {
+		return Entry{}, err
+	}
+	e := Entry{
+		Type:  (*hexutil.Uint64)(ptr(uint64(tx.Type()))),
+		From:  &from,
+		To:    tx.To(),
+		Nonce: (*hexutil.Uint64)(ptr(tx.Nonce())),
+		Value: (*hexutil.Big)(tx.Value()),
+		Gas:   (*hexutil.Uint64)(ptr(tx.Gas())),
+		Raw:   raw,
+	}
+	switch tx.Type() {
+	case types.LegacyTxType, types.AccessListTxType:
+		e.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	default:
+		e.GasTipCap, e.GasFeeCap = (*hexutil.Big)(tx.GasTipCap()), (*hexutil.Big)(tx.GasFeeCap())
+	}
+	if tx.Type() == types.BlobTxType {
+		e.BlobFeeCap = (*hexutil.Big)(tx.BlobGasFeeCap())
+		e.BlobHashes = tx.BlobHashes()
+	}
+	return e, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,73 @@
+package journal
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestBuiltRecords(t *testing.T) {
+	jrnl, err := Open(filepath.Join(t.TempDir(), "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	transfer := func(nonce uint64) *types.Transaction {
+		return types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: big.NewInt(params.GWei), Gas: params.TxGas, To: &to, Value: big.NewInt(1)})
+	}
+
+	// Nonce 0 is signed after it was built, nonce 1 never is, nonce 2 is
+	// signed without chain ID
+	for _, tc := range []struct {
+		signer types.Signer
+		nonce  uint64
+		sign   bool
+	}{
+		{types.LatestSignerForChainID(big.NewInt(1)), 0, true},
+		{types.LatestSignerForChainID(big.NewInt(1)), 1, false},
+		{types.HomesteadSigner{}, 2, true},
+	} {
+		tx := transfer(tc.nonce)
+		if err := jrnl.RecordBuilt(tx, from, tc.signer); err != nil {
+			t.Fatal(err)
+		}
+		if !tc.sign {
+			continue
+		}
+		signed, err := types.SignTx(tx, tc.signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := NewEntry("", signed, from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := jrnl.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := jrnl.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Status{StatusSigned, StatusBuilt, StatusSigned}
+	if len(records) != len(want) {
+		t.Fatalf("%d records, want %d", len(records), len(want))
+	}
+	for i, r := range records {
+		if r.Status != want[i] || uint64(*r.Nonce) != uint64(i) {
+			t.Errorf("record %d: nonce %d %s, want nonce %d %s", i, uint64(*r.Nonce), r.Status, i, want[i])
+		}
+	}
+	if built := records[1]; !built.Final() || built.Pending() || built.ChainID.ToInt().Int64() != 1 || *built.From != from {
+		t.Errorf("built record %+v", built.Entry)
+	}
+}
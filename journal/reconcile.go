@@ -0,0 +1,169 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Change is a status update made by Reconcile.
+type Change struct {
+	Hash     common.Hash
+	From     Status
+	To       Status
+	Finality Finality
+	Error    string
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s: %s -> %s", c.Hash.Hex(), c.From, c.To)
+	if c.Finality != "" {
+		s += " (" + string(c.Finality) + ")"
+	}
+	if c.Error != "" {
+		s += ": " + c.Error
+	}
+	return s
+}
+
+// Reconcile asks the node behind network about every recorded transaction
+// that isn't final yet and journals the ones that changed. Mined transactions
+// are checked until their block is finalized: a receipt whose block is no
+// longer canonical means the transaction was reorged out.
+func (j *Journal) Reconcile(ctx context.Context, client *ethclient.Client, network string) ([]Change, error) {
+	records, err := j.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	// Nodes without a beacon chain know neither tag, everything stays
+	// unfinalized there
+	safe, _ := client.HeaderByNumber(ctx, big.NewInt(int64(rpc.SafeBlockNumber)))
+	finalized, _ := client.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+
+	var changes []Change
+	for _, r := range records {
+		if r.Network != network || r.Final() {
+			continue
+		}
+		update, err := status(ctx, client, r, safe, finalized)
+		if err != nil {
+			return changes, fmt.Errorf("%s: %w", r.Hash.Hex(), err)
+		}
+		if update.Status == r.Status && update.Finality == r.Finality && equalHash(update.BlockHash, r.BlockHash) {
+			continue
+		}
+		if err := j.Append(update); err != nil {
+			return changes, err
+		}
+		changes = append(changes, Change{Hash: r.Hash, From: r.Status, To: update.Status, Finality: update.Finality, Error: update.Error})
+	}
+	return changes, nil
+}
+
+// status works out the current status of a recorded transaction.
+func status(ctx context.Context, client *ethclient.Client, r *Record, safe, finalized *types.Header) (Entry, error) {
+	update := Entry{Hash: r.Hash}
+
+	receipt, err := client.TransactionReceipt(ctx, r.Hash)
+	switch {
+	case err == nil:
+		// Receipts are looked up by hash, make sure the block they point to
+		// is still the canonical one at that height
+		header, err := client.HeaderByNumber(ctx, receipt.BlockNumber)
+		if err != nil {
+			return update, fmt.Errorf("failed to fetch block %s: %w", receipt.BlockNumber, err)
+		}
+		if header.Hash() != receipt.BlockHash {
+			break
+		}
+		update.Status = StatusIncluded
+		if receipt.Status == 0 {
+			update.Status = StatusReverted
+		}
+		update.BlockNumber = (*hexutil.Big)(receipt.BlockNumber)
+		update.BlockHash = &receipt.BlockHash
+		update.GasUsed = (*hexutil.Uint64)(&receipt.GasUsed)
+		update.Finality = finality(receipt.BlockNumber, safe, finalized)
+		if r.Mined() && r.BlockHash != nil && *r.BlockHash != receipt.BlockHash {
+			update.Error = fmt.Sprintf("reorged out of block %s %s", r.BlockNumber.ToInt(), r.BlockHash.Hex())
+		}
+		return update, nil
+	case isIndexing(err):
+		// The node can't tell yet, ask again later
+		update.Status, update.Finality, update.BlockHash = r.Status, r.Finality, r.BlockHash
+		return update, nil
+	case !errors.Is(err, ethereum.NotFound):
+		return update, err
+	}
+
+	// Not in the chain. If it was mined before, its block was reorged out
+	var reorged string
+	if r.Mined() {
+		reorged = fmt.Sprintf("reorged out of block %s", r.BlockNumber.ToInt())
+	}
+
+	// The node may still have it in its pool
+	if _, _, err := client.TransactionByHash(ctx, r.Hash); err == nil {
+		update.Status = StatusPending
+		if reorged != "" {
+			update.Error = reorged + ", back in the pool"
+		}
+		return update, nil
+	} else if !errors.Is(err, ethereum.NotFound) {
+		return update, err
+	}
+
+	// Unknown to the node: either another transaction took the nonce, or it
+	// is gone and can be sent again
+	update.Status = StatusDropped
+	if reorged != "" || r.Status == StatusReorged {
+		update.Status, update.Error = StatusReorged, reorged
+	}
+	if r.From == nil || r.Nonce == nil {
+		return update, nil
+	}
+	nonce, err := client.NonceAt(ctx, *r.From, nil)
+	if err != nil {
+		return update, err
+	}
+	if nonce > uint64(*r.Nonce) {
+		update.Status, update.Error = StatusReplaced, ""
+	}
+	return update, nil
+}
+
+// finality returns how settled block number is, given the node's safe and
+// finalized heads.
+func finality(number *big.Int, safe, finalized *types.Header) Finality {
+	switch {
+	case finalized != nil && finalized.Number.Cmp(number) >= 0:
+		return FinalityFinalized
+	case safe != nil && safe.Number.Cmp(number) >= 0:
+		return FinalitySafe
+	default:
+		return ""
+	}
+}
+
+func equalHash(a, b *common.Hash) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// isIndexing reports whether the node refused a receipt lookup because it is
+// still indexing transactions (geth after startup or a snapshot sync).
+func isIndexing(err error) bool {
+	return strings.Contains(err.Error(), "indexing is in progress")
+}
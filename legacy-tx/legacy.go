@@ -1,14 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"math/big"
+	"os"
+	"strings"
 	"time"
 	"transactiontypes/account"
+	"transactiontypes/builder"
+	"transactiontypes/journal"
+	"transactiontypes/monitor"
+	"transactiontypes/policy"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/samber/lo"
 )
@@ -22,6 +32,11 @@ const (
 )
 
 func main() {
+	unprotected := flag.Bool("unprotected", false, "sign without chain ID (pre-EIP-155), valid on every chain")
+	yes := flag.Bool("yes", false, "skip the confirmation for -unprotected and -broadcast")
+	rawTx := flag.String("broadcast", "", "broadcast an already signed raw transaction, e.g. a keyless deployment")
+	flag.Parse()
+
 	acc1Addr, acc1Priv := account.GetAccount(1)
 	acc2Addr, _ := account.GetAccount(2)
 
@@ -31,6 +46,11 @@ func main() {
 		log.Fatal("Failed to connect to Ethereum node:", err)
 	}
 
+	if *rawTx != "" {
+		broadcastRaw(ctx, client, *rawTx, *yes)
+		return
+	}
+
 	nonce, err := client.PendingNonceAt(ctx, lo.FromPtr(acc1Addr))
 	if err != nil {
 		log.Fatal("Failed to fetch nonce:", err)
@@ -42,44 +62,134 @@ func main() {
 		log.Fatal("Failed to fetch gas price:", err)
 	}
 
-	// Create a types.LegacyTx
-	tx := types.LegacyTx{
-		Nonce:    nonce,
-		GasPrice: gasPrice,
-		Gas:      GasLimit,
+	chainID := big.NewInt(AmoyChainID) // Use your chain's ID (80002 = Polygon Mumbai Testnet)
+
+	// Describe a legacy transaction
+	req := &builder.Request{
+		Type:     types.LegacyTxType,
+		ChainID:  chainID,
+		From:     *acc1Addr,
 		To:       acc2Addr, // Send to account 2
+		Nonce:    nonce,
 		Value:    big.NewInt(ValueToSend),
-		Data:     []byte{},
+		Gas:      GasLimit,
+		GasPrice: gasPrice,
+	}
+
+	spending, err := policy.Default()
+	if err != nil {
+		log.Fatal("Failed to load spending policy:", err)
+	}
+	if err := spending.Check(req); err != nil {
+		log.Fatal(err)
 	}
 
 	// Convert it into a full types.Transaction object
-	legacyTx := types.NewTx(&tx)
+	legacyTx, err := req.Build()
+	if err != nil {
+		log.Fatal("Failed to build transaction:", err)
+	}
 
-	chainID := big.NewInt(AmoyChainID) // Use your chain's ID (80002 = Polygon Mumbai Testnet)
-	// Note: Although this is a legacy transaction, we still need to sign it with the chain ID for EIP-155 compatibility.
-	// Because most of the nodes protect against replay attacks by requiring the chain ID in the signature.
-	// Nodes that not protect against this will be able to get signed transaction with:
-	// types.SignTx(tx, types.HomesteadSigner{}, priv)
-	signedTx, err := types.SignTx(legacyTx, types.NewEIP155Signer(chainID), acc1Priv)
+	// Note: Although this is a legacy transaction, we still sign it with the chain ID for EIP-155 compatibility
+	// by default. Because most of the nodes protect against replay attacks by requiring the chain ID in the signature.
+	// With -unprotected the transaction is signed with types.HomesteadSigner{} instead.
+	if *unprotected {
+		fmt.Printf("WARNING: an unprotected transaction from %s with nonce %d can be replayed on every chain\n", acc1Addr.Hex(), nonce)
+		fmt.Println("where this account has the same nonce and enough funds.")
+		confirm(*yes)
+		if err := builder.CheckUnprotectedAllowed(ctx, client); err != nil {
+			log.Fatal("Cannot send unprotected transactions through this node (geth needs --rpc.allow-unprotected-txs):", err)
+		}
+	}
+	signer, err := req.Signer(*unprotected)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Transactions are journaled from the moment they are built, see the
+	// history command
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	if err := jrnl.RecordBuilt(legacyTx, *acc1Addr, signer); err != nil {
+		log.Fatal("Failed to journal transaction:", err)
+	}
+	signedTx, err := types.SignTx(legacyTx, signer, acc1Priv)
 	if err != nil {
 		log.Fatal("Failed to sign transaction:", err)
 	}
 
 	// Broadcast the transaction
-	err = client.SendTransaction(ctx, signedTx)
+	err = jrnl.Broadcast(ctx, client, NodeRPCURL, signedTx, *acc1Addr)
 	if err != nil {
 		log.Fatal("Broadcast failed:", err)
 	}
 
 	fmt.Println("Transaction sent!")
 	fmt.Println("Tx hash:", signedTx.Hash().Hex())
+	waitForReceipt(ctx, client, signedTx, *acc1Addr)
+}
+
+// broadcastRaw sends a signed transaction as is. Keyless deployments (a made
+// up signature on an unprotected transaction) are sent this way: nobody has
+// the sender's key, the same bytes deploy to the same address on every chain.
+func broadcastRaw(ctx context.Context, client *ethclient.Client, raw string, yes bool) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(common.FromHex(raw)); err != nil {
+		log.Fatal("Failed to decode transaction:", err)
+	}
+
+	from, err := builder.Sender(tx)
+	if err != nil {
+		log.Fatal("Failed to recover sender:", err)
+	}
+
+	if !tx.Protected() {
+		fmt.Printf("Unprotected transaction from %s, nonce %d", from.Hex(), tx.Nonce())
+		if tx.To() == nil {
+			fmt.Printf(", deploys to %s", crypto.CreateAddress(from, tx.Nonce()).Hex())
+		}
+		fmt.Println()
+		fmt.Println("It is valid on every chain where the sender has this nonce and enough funds.")
+		confirm(yes)
+		if err := builder.CheckUnprotectedAllowed(ctx, client); err != nil {
+			log.Fatal("Cannot send unprotected transactions through this node (geth needs --rpc.allow-unprotected-txs):", err)
+		}
+	}
+
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	if err := jrnl.Broadcast(ctx, client, NodeRPCURL, tx, from); err != nil {
+		log.Fatal("Broadcast failed:", err)
+	}
+	fmt.Println("Transaction sent!")
+	fmt.Println("Tx hash:", tx.Hash().Hex())
+	waitForReceipt(ctx, client, tx, from)
+}
+
+// confirm asks before doing something that can't be taken back.
+func confirm(yes bool) {
+	if yes {
+		return
+	}
+	fmt.Print("Type yes to continue: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != "yes" {
+		log.Fatal("Aborted")
+	}
+}
 
+func waitForReceipt(ctx context.Context, client *ethclient.Client, tx *types.Transaction, from common.Address) {
 	// Optionally wait for inclusion
-	time.Sleep(10 * time.Second)
-	receipt, err := client.TransactionReceipt(ctx, signedTx.Hash())
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	mined, err := monitor.Wait(ctx, client, tx, from, monitor.StatusIncluded)
 	if err != nil {
-		fmt.Println("Tx not mined yet.")
+		fmt.Println("Tx not mined yet:", err)
 	} else {
-		fmt.Println("Tx mined in block:", receipt.BlockNumber)
+		fmt.Println("Tx mined in block:", mined.BlockNumber)
 	}
 }
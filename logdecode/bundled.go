@@ -0,0 +1,50 @@
+package logdecode
+
+import (
+	"strings"
+	"transactiontypes/erc20"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// erc721ABI holds the ERC-721 events. Transfer and Approval share their
+// signatures with ERC-20 but index the token ID, so the decoder picks by
+// topic count.
+const erc721ABI = `[
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+	{"type":"event","name":"Approval","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"approved","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+	{"type":"event","name":"ApprovalForAll","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"operator","type":"address","indexed":true},{"name":"approved","type":"bool","indexed":false}]}
+]`
+
+// Events of the contracts in this repository (eip7702/*.sol, eip4844/*.sol).
+const (
+	invokedABI = `[
+	{"type":"event","name":"Pinged","anonymous":false,"inputs":[{"name":"sender","type":"address","indexed":false}]}
+]`
+	multiDelegationInvokerABI = `[
+	{"type":"event","name":"PingStart","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":false}]},
+	{"type":"event","name":"PingSuccess","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":false}]}
+]`
+	pointEvaluationVerifierABI = `[
+	{"type":"event","name":"PointVerified","anonymous":false,"inputs":[{"name":"versionedHash","type":"bytes32","indexed":false},{"name":"z","type":"bytes32","indexed":false},{"name":"y","type":"bytes32","indexed":false}]}
+]`
+)
+
+var bundled = []struct {
+	name string
+	abi  abi.ABI
+}{
+	{"ERC20", erc20.ABI},
+	{"ERC721", mustParse(erc721ABI)},
+	{"Invoked", mustParse(invokedABI)},
+	{"MultiDelegationInvoker", mustParse(multiDelegationInvokerABI)},
+	{"PointEvaluationVerifier", mustParse(pointEvaluationVerifierABI)},
+}
+
+func mustParse(abiJSON string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
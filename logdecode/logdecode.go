@@ -0,0 +1,151 @@
+// Package logdecode turns receipt logs into named events, using the ABIs of
+// known contracts and falling back to a local event signature database.
+package logdecode
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Source tells how an event was identified.
+type Source int
+
+const (
+	SourceUnknown   Source = iota // topic not found, only raw data available
+	SourceABI                     // decoded with a registered contract ABI
+	SourceSignature               // matched in the signature database, indexing guessed
+)
+
+// Arg is one decoded event parameter.
+type Arg struct {
+	Name    string
+	Type    string
+	Indexed bool
+	Value   interface{}
+}
+
+// Event is a decoded log.
+type Event struct {
+	Log       *types.Log
+	Source    Source
+	Contract  string // name the ABI was registered under, empty for SourceSignature matches
+	Name      string
+	Signature string
+	Args      []Arg
+}
+
+// String renders the event on one line per parameter.
+func (e *Event) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "log %d @ %s: ", e.Log.Index, e.Log.Address.Hex())
+	switch e.Source {
+	case SourceABI:
+		fmt.Fprintf(&b, "%s.%s", e.Contract, e.Signature)
+	case SourceSignature:
+		fmt.Fprintf(&b, "%s (signature database, parameter names and indexing guessed)", e.Signature)
+	default:
+		b.WriteString("unknown event")
+		for i, topic := range e.Log.Topics {
+			fmt.Fprintf(&b, "\n  topic[%d]: %s", i, topic.Hex())
+		}
+		if len(e.Log.Data) > 0 {
+			fmt.Fprintf(&b, "\n  data: %s", hexutil.Encode(e.Log.Data))
+		}
+		return b.String()
+	}
+	for _, arg := range e.Args {
+		indexed := ""
+		if arg.Indexed {
+			indexed = " indexed"
+		}
+		fmt.Fprintf(&b, "\n  %s %s%s: %s", arg.Type, arg.Name, indexed, FormatValue(arg.Value))
+	}
+	return b.String()
+}
+
+// FormatValue renders a decoded ABI value for display.
+func FormatValue(v interface{}) string {
+	switch v := v.(type) {
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case *big.Int:
+		return v.String()
+	case [32]byte:
+		return hexutil.Encode(v[:])
+	case []common.Address:
+		s := make([]string, len(v))
+		for i, addr := range v {
+			s[i] = addr.Hex()
+		}
+		return "[" + strings.Join(s, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// decodeEvent decodes l with ev. Topics and data have to match the event's
+// indexed and non-indexed inputs exactly.
+func decodeEvent(ev abi.Event, l *types.Log) ([]Arg, error) {
+	// ParseTopicsIntoMap and UnpackIntoMap key by name, so unnamed
+	// parameters need one
+	inputs := make(abi.Arguments, len(ev.Inputs))
+	var indexed abi.Arguments
+	for i, input := range ev.Inputs {
+		if input.Name == "" {
+			input.Name = fmt.Sprintf("arg%d", i)
+		}
+		inputs[i] = input
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+
+	topics := l.Topics
+	if !ev.Anonymous {
+		if len(topics) == 0 || topics[0] != ev.ID {
+			return nil, fmt.Errorf("topic does not match %s", ev.Sig)
+		}
+		topics = topics[1:]
+	}
+	values := make(map[string]interface{}, len(inputs))
+	if err := abi.ParseTopicsIntoMap(values, indexed, topics); err != nil {
+		return nil, err
+	}
+	if err := inputs.NonIndexed().UnpackIntoMap(values, l.Data); err != nil {
+		return nil, err
+	}
+	// Unpack ignores trailing bytes, a layout mismatch must not pass as a match
+	if size := dataSize(inputs.NonIndexed()); size >= 0 && size != len(l.Data) {
+		return nil, fmt.Errorf("data is %d bytes, %s expects %d", len(l.Data), ev.Sig, size)
+	}
+
+	args := make([]Arg, len(inputs))
+	for i, input := range inputs {
+		args[i] = Arg{Name: input.Name, Type: input.Type.String(), Indexed: input.Indexed, Value: values[input.Name]}
+	}
+	return args, nil
+}
+
+// dataSize returns the encoded size of static non-indexed arguments, -1 if
+// any of them is dynamic.
+func dataSize(args abi.Arguments) int {
+	size := 0
+	for _, arg := range args {
+		switch arg.Type.T {
+		case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+			return -1
+		}
+		size += 32
+	}
+	return size
+}
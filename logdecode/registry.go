@@ -0,0 +1,121 @@
+package logdecode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// registeredEvent is an event of a registered contract ABI.
+type registeredEvent struct {
+	contract  string
+	event     abi.Event
+	addresses map[common.Address]bool // empty if the ABI applies to any address
+}
+
+// Registry maps event topics to the ABIs of known contracts.
+type Registry struct {
+	events     map[common.Hash][]registeredEvent
+	signatures *SignatureDB
+}
+
+// NewRegistry returns an empty registry that falls back to signatures for
+// unknown topics. signatures may be nil.
+func NewRegistry(signatures *SignatureDB) *Registry {
+	return &Registry{events: make(map[common.Hash][]registeredEvent), signatures: signatures}
+}
+
+// Default returns a registry with the bundled ABIs and the embedded signature
+// database.
+func Default() *Registry {
+	r := NewRegistry(DefaultSignatures())
+	for _, c := range bundled {
+		r.Add(c.name, c.abi)
+	}
+	return r
+}
+
+// Add registers the events of contract under name. If addresses are given,
+// the ABI is preferred for logs emitted by them; without, it applies to logs
+// of any address.
+func (r *Registry) Add(name string, contract abi.ABI, addresses ...common.Address) {
+	var bound map[common.Address]bool
+	if len(addresses) > 0 {
+		bound = make(map[common.Address]bool, len(addresses))
+		for _, addr := range addresses {
+			bound[addr] = true
+		}
+	}
+	for _, ev := range contract.Events {
+		if ev.Anonymous {
+			// Anonymous events have no topic to look them up by
+			continue
+		}
+		r.events[ev.ID] = append(r.events[ev.ID], registeredEvent{contract: name, event: ev, addresses: bound})
+	}
+}
+
+// AddJSON parses a JSON ABI and registers it like Add.
+func (r *Registry) AddJSON(name, abiJSON string, addresses ...common.Address) error {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	r.Add(name, parsed, addresses...)
+	return nil
+}
+
+// Decode identifies and decodes a single log. It never fails: logs that match
+// nothing come back as SourceUnknown with their raw topics and data.
+func (r *Registry) Decode(l *types.Log) *Event {
+	event := &Event{Log: l}
+	if len(l.Topics) == 0 {
+		return event
+	}
+
+	// The same signature may be registered several times with different
+	// indexing (ERC-20 and ERC-721 Transfer). Try ABIs bound to the emitting
+	// address first, then the rest, and keep the first that fits.
+	candidates := r.events[l.Topics[0]]
+	for _, bound := range []bool{true, false} {
+		for _, c := range candidates {
+			if c.addresses[l.Address] != bound {
+				continue
+			}
+			args, err := decodeEvent(c.event, l)
+			if err != nil {
+				continue
+			}
+			event.Source, event.Contract, event.Name, event.Signature, event.Args = SourceABI, c.contract, c.event.Name, c.event.Sig, args
+			return event
+		}
+	}
+
+	if r.signatures != nil {
+		for _, sig := range r.signatures.Lookup(l.Topics[0]) {
+			ev, err := guessEvent(sig, len(l.Topics)-1)
+			if err != nil {
+				continue
+			}
+			args, err := decodeEvent(ev, l)
+			if err != nil {
+				continue
+			}
+			event.Source, event.Name, event.Signature, event.Args = SourceSignature, ev.Name, ev.Sig, args
+			return event
+		}
+	}
+	return event
+}
+
+// DecodeReceipt decodes all logs of a receipt in order.
+func (r *Registry) DecodeReceipt(receipt *types.Receipt) []*Event {
+	events := make([]*Event, len(receipt.Logs))
+	for i, l := range receipt.Logs {
+		events[i] = r.Decode(l)
+	}
+	return events
+}
@@ -0,0 +1,101 @@
+package logdecode
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// embeddedSignatures is a small offline subset of the 4byte.directory event
+// signatures, one canonical signature per line.
+//
+//go:embed signatures.txt
+var embeddedSignatures []byte
+
+// SignatureDB maps event topics to text signatures. Different signatures can
+// share a topic only by hash collision, so usually a topic has one entry.
+type SignatureDB struct {
+	byTopic map[common.Hash][]string
+}
+
+// LoadSignatures reads canonical event signatures such as
+// "Transfer(address,address,uint256)", one per line. Blank lines and lines
+// starting with # are ignored.
+func LoadSignatures(r io.Reader) (*SignatureDB, error) {
+	db := &SignatureDB{byTopic: make(map[common.Hash][]string)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		sig := strings.TrimSpace(scanner.Text())
+		if sig == "" || strings.HasPrefix(sig, "#") {
+			continue
+		}
+		if _, err := abi.ParseSelector(sig); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		db.Add(sig)
+	}
+	return db, scanner.Err()
+}
+
+var (
+	defaultSignaturesOnce sync.Once
+	defaultSignatures     *SignatureDB
+)
+
+// DefaultSignatures returns the embedded signature database.
+func DefaultSignatures() *SignatureDB {
+	defaultSignaturesOnce.Do(func() {
+		db, err := LoadSignatures(bytes.NewReader(embeddedSignatures))
+		if err != nil {
+			panic(fmt.Sprintf("embedded signatures: %v", err))
+		}
+		defaultSignatures = db
+	})
+	return defaultSignatures
+}
+
+// Add inserts a canonical signature.
+func (db *SignatureDB) Add(sig string) {
+	topic := crypto.Keccak256Hash([]byte(sig))
+	for _, known := range db.byTopic[topic] {
+		if known == sig {
+			return
+		}
+	}
+	db.byTopic[topic] = append(db.byTopic[topic], sig)
+}
+
+// Lookup returns the signatures hashing to topic.
+func (db *SignatureDB) Lookup(topic common.Hash) []string {
+	return db.byTopic[topic]
+}
+
+// guessEvent builds an event from a text signature. Signatures don't record
+// which parameters are indexed, so the first numIndexed are assumed to be,
+// which is how nearly all contracts declare them.
+func guessEvent(sig string, numIndexed int) (abi.Event, error) {
+	selector, err := abi.ParseSelector(sig)
+	if err != nil {
+		return abi.Event{}, err
+	}
+	if numIndexed > len(selector.Inputs) {
+		return abi.Event{}, fmt.Errorf("%s has fewer than %d parameters", sig, numIndexed)
+	}
+	inputs := make(abi.Arguments, len(selector.Inputs))
+	for i, in := range selector.Inputs {
+		typ, err := abi.NewType(in.Type, "", in.Components)
+		if err != nil {
+			return abi.Event{}, err
+		}
+		inputs[i] = abi.Argument{Name: fmt.Sprintf("arg%d", i), Type: typ, Indexed: i < numIndexed}
+	}
+	return abi.NewEvent(selector.Name, selector.Name, false, inputs), nil
+}
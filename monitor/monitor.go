@@ -0,0 +1,404 @@
+// Package monitor follows sent transactions from the node's pool to a
+// finalized block.
+//
+// A Monitor subscribes to new heads and re-checks its transactions on each
+// one, against the node's "safe" and "finalized" block tags. A receipt only
+// counts while its block is canonical, so a transaction reorged out of its
+// block is reported as such. Where the node offers it (geth over websocket)
+// it also subscribes to the pool, to see a transaction arrive and to spot a
+// replacement with the same nonce before it is mined. Over HTTP, which has no
+// subscriptions, heads are polled instead.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+	"transactiontypes/builder"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Status is how far a transaction got.
+type Status int
+
+const (
+	StatusUnknown   Status = iota // not seen yet
+	StatusPending                 // in the pool, or back in it after a reorg
+	StatusIncluded                // in a block
+	StatusConfirmed               // enough blocks on top of it
+	StatusSafe                    // in a block the node considers safe
+	StatusFinalized               // in a finalized block
+	StatusReplacing               // another transaction with the nonce is in the pool
+	StatusReplaced                // another transaction with the nonce was mined
+	StatusReorged                 // was mined, its block left the chain
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPending:
+		return "pending"
+	case StatusIncluded:
+		return "included"
+	case StatusConfirmed:
+		return "confirmed"
+	case StatusSafe:
+		return "safe"
+	case StatusFinalized:
+		return "finalized"
+	case StatusReplacing:
+		return "replacing"
+	case StatusReplaced:
+		return "replaced"
+	case StatusReorged:
+		return "reorged"
+	default:
+		return "unknown"
+	}
+}
+
+// mined reports whether the status means the transaction is in a block.
+func (s Status) mined() bool {
+	return s >= StatusIncluded && s <= StatusFinalized
+}
+
+// Event is a status change of a watched transaction.
+type Event struct {
+	Hash   common.Hash
+	Status Status
+
+	// Set while the transaction is mined, and for StatusReorged to the block
+	// it was reorged out of
+	BlockNumber   uint64
+	BlockHash     common.Hash
+	Confirmations uint64
+	Receipt       *types.Receipt
+
+	// The other transaction for StatusReplacing, and for StatusReplaced if
+	// it was seen in the pool
+	ReplacedBy common.Hash
+}
+
+func (e Event) String() string {
+	switch {
+	case e.Status.mined():
+		return fmt.Sprintf("%s %s in block %d (%d confirmations)", e.Hash.Hex(), e.Status, e.BlockNumber, e.Confirmations)
+	case e.Status == StatusReorged:
+		return fmt.Sprintf("%s reorged out of block %d (%s)", e.Hash.Hex(), e.BlockNumber, e.BlockHash.Hex())
+	case e.ReplacedBy != (common.Hash{}):
+		return fmt.Sprintf("%s %s by %s", e.Hash.Hex(), e.Status, e.ReplacedBy.Hex())
+	default:
+		return fmt.Sprintf("%s %s", e.Hash.Hex(), e.Status)
+	}
+}
+
+// watched is the state of one transaction, only touched by Run.
+type watched struct {
+	hash        common.Hash
+	from        common.Address
+	nonce       uint64
+	status      Status
+	blockNumber uint64
+	blockHash   common.Hash
+	rival       common.Hash
+}
+
+// Monitor follows a set of transactions. Add them with Watch and follow them
+// with Run.
+type Monitor struct {
+	// PollInterval is the head polling interval over HTTP.
+	PollInterval time.Duration
+
+	client        *ethclient.Client
+	confirmations uint64
+	noFinality    bool // the node doesn't report finalized blocks
+
+	mu      sync.Mutex
+	watched map[common.Hash]*watched
+}
+
+// New creates a monitor that reports a transaction confirmed once it has
+// confirmations blocks including its own (at least 1).
+func New(client *ethclient.Client, confirmations uint64) *Monitor {
+	return &Monitor{
+		PollInterval:  2 * time.Second,
+		client:        client,
+		confirmations: max(confirmations, 1),
+		watched:       make(map[common.Hash]*watched),
+	}
+}
+
+// Watch adds the transaction with hash, sent by from with nonce. It may be
+// called while Run is running.
+func (m *Monitor) Watch(hash common.Hash, from common.Address, nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.watched[hash]; !ok {
+		m.watched[hash] = &watched{hash: hash, from: from, nonce: nonce}
+	}
+}
+
+// Run follows the watched transactions and sends their status changes to
+// events. It returns when all of them are finalized or replaced (confirmed,
+// if the node has no finality), or when ctx is done.
+func (m *Monitor) Run(ctx context.Context, events chan<- Event) error {
+	heads := make(chan *types.Header, 16)
+	var (
+		tick   <-chan time.Time
+		subErr <-chan error
+	)
+	sub, err := m.client.SubscribeNewHead(ctx, heads)
+	switch {
+	case errors.Is(err, rpc.ErrNotificationsUnsupported):
+		ticker := time.NewTicker(m.PollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	case err != nil:
+		return fmt.Errorf("failed to subscribe to new heads: %w", err)
+	default:
+		defer sub.Unsubscribe()
+		subErr = sub.Err()
+	}
+
+	// The pool subscription is geth specific and optional
+	pool := make(chan *types.Transaction, 256)
+	var poolErr <-chan error
+	if poolSub, err := gethclient.New(m.client.Client()).SubscribeFullPendingTransactions(ctx, pool); err == nil {
+		defer poolSub.Unsubscribe()
+		poolErr = poolSub.Err()
+	}
+
+	// The transactions may have been mined before Run was called
+	if err := m.check(ctx, nil, events); err != nil {
+		return err
+	}
+	for !m.done() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-subErr:
+			return fmt.Errorf("head subscription failed: %w", err)
+		case <-poolErr:
+			poolErr = nil
+		case tx := <-pool:
+			if err := m.seen(ctx, tx, events); err != nil {
+				return err
+			}
+		case head := <-heads:
+			if err := m.check(ctx, head, events); err != nil {
+				return err
+			}
+		case <-tick:
+			if err := m.check(ctx, nil, events); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// list returns the transactions that can still change.
+func (m *Monitor) list() []*watched {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []*watched
+	for _, w := range m.watched {
+		if !m.final(w.status) {
+			list = append(list, w)
+		}
+	}
+	return list
+}
+
+func (m *Monitor) final(s Status) bool {
+	return s == StatusFinalized || s == StatusReplaced || (m.noFinality && s == StatusConfirmed)
+}
+
+func (m *Monitor) done() bool {
+	return len(m.list()) == 0
+}
+
+// seen handles a transaction that entered the node's pool.
+func (m *Monitor) seen(ctx context.Context, tx *types.Transaction, events chan<- Event) error {
+	var sender *common.Address
+	for _, w := range m.list() {
+		if w.status.mined() {
+			continue
+		}
+		if tx.Hash() == w.hash {
+			if w.status == StatusUnknown || w.status == StatusReorged {
+				w.status = StatusPending
+				if err := send(ctx, events, Event{Hash: w.hash, Status: StatusPending}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tx.Nonce() != w.nonce || tx.Hash() == w.rival {
+			continue
+		}
+		// Recovering the sender is the expensive part, only do it for
+		// transactions with a nonce of interest
+		if sender == nil {
+			from, err := builder.Sender(tx)
+			if err != nil {
+				return nil
+			}
+			sender = &from
+		}
+		if *sender == w.from {
+			w.status, w.rival = StatusReplacing, tx.Hash()
+			if err := send(ctx, events, Event{Hash: w.hash, Status: StatusReplacing, ReplacedBy: w.rival}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// check re-checks all transactions against head, the latest head if nil.
+func (m *Monitor) check(ctx context.Context, head *types.Header, events chan<- Event) error {
+	list := m.list()
+	if len(list) == 0 {
+		return nil
+	}
+	if head == nil {
+		var err error
+		if head, err = m.client.HeaderByNumber(ctx, nil); err != nil {
+			return fmt.Errorf("failed to fetch head: %w", err)
+		}
+	}
+	// Chains without a beacon chain have neither a safe nor a finalized block
+	safe, _ := m.client.HeaderByNumber(ctx, big.NewInt(int64(rpc.SafeBlockNumber)))
+	finalized, err := m.client.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+	m.noFinality = err != nil
+
+	for _, w := range list {
+		changes, err := m.update(ctx, w, head, safe, finalized)
+		if err != nil {
+			return fmt.Errorf("%s: %w", w.hash.Hex(), err)
+		}
+		for _, e := range changes {
+			if err := send(ctx, events, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// update moves w along and returns the events for the steps it took.
+func (m *Monitor) update(ctx context.Context, w *watched, head, safe, finalized *types.Header) ([]Event, error) {
+	var changes []Event
+	receipt, err := m.client.TransactionReceipt(ctx, w.hash)
+	switch {
+	case err == nil:
+		// Receipts are looked up by hash, make sure the block they point to
+		// is still the canonical one at that height
+		canonical, err := m.client.HeaderByNumber(ctx, receipt.BlockNumber)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch block %s: %w", receipt.BlockNumber, err)
+		}
+		if canonical.Hash() != receipt.BlockHash {
+			break
+		}
+		number := receipt.BlockNumber.Uint64()
+		event := func(s Status) Event {
+			e := Event{Hash: w.hash, Status: s, BlockNumber: number, BlockHash: receipt.BlockHash, Receipt: receipt}
+			// head may lag behind the receipt, the block itself counts
+			e.Confirmations = 1
+			if head.Number.Uint64() > number {
+				e.Confirmations = head.Number.Uint64() - number + 1
+			}
+			return e
+		}
+		// A different block hash means a reorg moved it to another block
+		if w.status.mined() && receipt.BlockHash != w.blockHash {
+			changes = append(changes, m.reorged(w))
+		}
+		if !w.status.mined() {
+			w.status, w.blockNumber, w.blockHash = StatusIncluded, number, receipt.BlockHash
+			changes = append(changes, event(StatusIncluded))
+		}
+
+		// Finalized implies safe, safe implies confirmed, whatever the depth
+		isFinalized := finalized != nil && finalized.Number.Uint64() >= number
+		isSafe := isFinalized || safe != nil && safe.Number.Uint64() >= number
+		steps := []struct {
+			status  Status
+			reached bool
+		}{
+			{StatusConfirmed, isSafe || event(StatusConfirmed).Confirmations >= m.confirmations},
+			{StatusSafe, isSafe},
+			{StatusFinalized, isFinalized},
+		}
+		for _, step := range steps {
+			if !step.reached {
+				break
+			}
+			if w.status < step.status {
+				w.status = step.status
+				changes = append(changes, event(step.status))
+			}
+		}
+		return changes, nil
+	case isIndexing(err):
+		// The node can't tell yet, try again on the next head
+		return nil, nil
+	case !errors.Is(err, ethereum.NotFound):
+		return nil, err
+	}
+
+	// Not in the chain
+	if w.status.mined() {
+		changes = append(changes, m.reorged(w))
+	}
+	nonce, err := m.client.NonceAt(ctx, w.from, head.Number)
+	if err != nil {
+		return changes, err
+	}
+	if nonce > w.nonce {
+		w.status = StatusReplaced
+		return append(changes, Event{Hash: w.hash, Status: StatusReplaced, ReplacedBy: w.rival}), nil
+	}
+	if w.status == StatusUnknown || w.status == StatusReorged {
+		// Without a pool subscription this is the only way to see it arrive,
+		// or return to the pool after a reorg
+		if _, pending, err := m.client.TransactionByHash(ctx, w.hash); err == nil && pending {
+			w.status = StatusPending
+			changes = append(changes, Event{Hash: w.hash, Status: StatusPending})
+		}
+	}
+	return changes, nil
+}
+
+// reorged marks a mined transaction as reorged out of its block.
+func (m *Monitor) reorged(w *watched) Event {
+	e := Event{Hash: w.hash, Status: StatusReorged, BlockNumber: w.blockNumber, BlockHash: w.blockHash}
+	w.status, w.blockNumber, w.blockHash = StatusReorged, 0, common.Hash{}
+	return e
+}
+
+func send(ctx context.Context, events chan<- Event, e Event) error {
+	select {
+	case events <- e:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isIndexing reports whether the node refused a receipt lookup because it is
+// still indexing transactions.
+func isIndexing(err error) bool {
+	return strings.Contains(err.Error(), "indexing is in progress")
+}
@@ -0,0 +1,50 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ErrReplaced is returned by Wait when another transaction used the nonce.
+var ErrReplaced = errors.New("transaction replaced")
+
+// Wait follows tx, sent by from, until it reaches status, which is one of
+// StatusPending, StatusIncluded, StatusConfirmed (1 confirmation), StatusSafe
+// or StatusFinalized, and returns the event that got it there. A reorg on the
+// way doesn't end the wait, the transaction may be mined again.
+func Wait(ctx context.Context, client *ethclient.Client, tx *types.Transaction, from common.Address, status Status) (Event, error) {
+	if status < StatusPending || status > StatusFinalized {
+		return Event{}, fmt.Errorf("cannot wait for status %s", status)
+	}
+	m := New(client, 1)
+	m.Watch(tx.Hash(), from, tx.Nonce())
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := make(chan Event)
+	errc := make(chan error, 1)
+	go func() { errc <- m.Run(ctx, events) }()
+
+	for {
+		select {
+		case e := <-events:
+			if e.Status == StatusReplaced {
+				return e, fmt.Errorf("%w, nonce %d was used by another transaction", ErrReplaced, tx.Nonce())
+			}
+			if e.Status >= status && e.Status <= StatusFinalized {
+				return e, nil
+			}
+		case err := <-errc:
+			if err == nil {
+				// Run only ends early on chains without finality
+				err = fmt.Errorf("transaction %s can't become %s on this chain", tx.Hash().Hex(), status)
+			}
+			return Event{}, err
+		}
+	}
+}
@@ -1,37 +1,230 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 	"transactiontypes/account"
+	"transactiontypes/eip191"
+	"transactiontypes/sigverify"
+	"transactiontypes/siwe"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
 )
 
+const usage = `Usage:
+  personalsign sign   [-account 1|2] [-version 45|00] [-validator addr] [-text] <message>
+  personalsign verify [-version 45|00] [-validator addr] [-rpc url] [-text] <address> <message> <signature>
+  personalsign login  [-account 1|2] [-domain app.xyz] [-chain-id 80002]
+
+Messages starting with 0x are decoded as hex bytes, anything else is UTF-8
+text. A 0x message that is not valid hex is an error, pass -text to sign or
+verify it as text instead.
+Signatures are accepted with v as 0/1 or 27/28.`
+
 func main() {
-	_, acc2Priv := account.GetAccount(2)
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
 
-	message := []byte("Login to app.xyz")
-	prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	switch os.Args[1] {
+	case "sign":
+		sign(os.Args[2:])
+	case "verify":
+		verify(os.Args[2:])
+	case "login":
+		login(os.Args[2:])
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
 
-	hash := crypto.Keccak256Hash([]byte(prefixed))
+func sign(args []string) {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	accNum := fs.Int("account", 2, "account number to sign with")
+	version := fs.String("version", "45", "EIP-191 version byte in hex (45 or 00)")
+	validator := fs.String("validator", "", "intended validator address for version 00")
+	text := fs.Bool("text", false, "treat the message as UTF-8 text even if it starts with 0x")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal(usage)
+	}
+
+	message, err := parseMessage(fs.Arg(0), *text)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hash := messageHash(*version, *validator, message)
+
+	_, priv := account.GetAccount(*accNum)
 
 	// Sign the hash
-	signature, err := crypto.Sign(hash.Bytes(), acc2Priv)
+	signature, err := crypto.Sign(hash.Bytes(), priv)
 	if err != nil {
 		log.Fatal(err)
 	}
+	// Wallets return v as 27/28, do the same so the output can be used anywhere
+	signature[64] += 27
 
-	fmt.Printf("Message: %s\n", message)
+	fmt.Printf("Message: %s\n", fs.Arg(0))
 	fmt.Printf("Prefixed Hash: 0x%x\n", hash.Bytes())
 	fmt.Printf("Signature: 0x%x\n", signature)
 
-	// Recover the public key
-	pubKey, err := crypto.SigToPub(hash.Bytes(), signature)
+	// Recover the signer as a sanity check
+	recoveredAddr, err := sigverify.RecoverAddress(hash, signature)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	recoveredAddr := crypto.PubkeyToAddress(*pubKey)
 	fmt.Printf("Recovered Address: %s\n", recoveredAddr.Hex())
 }
+
+func verify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	version := fs.String("version", "45", "EIP-191 version byte in hex (45 or 00)")
+	validator := fs.String("validator", "", "intended validator address for version 00")
+	rpcURL := fs.String("rpc", "", "node RPC URL, enables ERC-1271/ERC-6492 checks for contract signers")
+	text := fs.Bool("text", false, "treat the message as UTF-8 text even if it starts with 0x")
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		log.Fatal(usage)
+	}
+
+	if !common.IsHexAddress(fs.Arg(0)) {
+		log.Fatalf("Invalid address: %s", fs.Arg(0))
+	}
+	signer := common.HexToAddress(fs.Arg(0))
+	message, err := parseMessage(fs.Arg(1), *text)
+	if err != nil {
+		log.Fatal(err)
+	}
+	signature, err := hexutil.Decode(fs.Arg(2))
+	if err != nil {
+		log.Fatal("Invalid signature hex:", err)
+	}
+
+	hash := messageHash(*version, *validator, message)
+	fmt.Printf("Prefixed Hash: 0x%x\n", hash.Bytes())
+
+	var valid bool
+	if *rpcURL == "" {
+		recoveredAddr, err := sigverify.RecoverAddress(hash, signature)
+		if err != nil {
+			log.Fatal("Failed to recover signer:", err)
+		}
+		fmt.Printf("Recovered Address: %s\n", recoveredAddr.Hex())
+		valid = recoveredAddr == signer
+	} else {
+		client, err := ethclient.Dial(*rpcURL)
+		if err != nil {
+			log.Fatal("Failed to connect to Ethereum node:", err)
+		}
+		valid, err = sigverify.NewVerifier(client).VerifySignature(context.Background(), signer, hash, signature)
+		if err != nil {
+			log.Fatal("Verification failed:", err)
+		}
+	}
+
+	fmt.Println("Signature valid?", valid)
+	if !valid {
+		os.Exit(1)
+	}
+}
+
+// login runs a full Sign-In with Ethereum round trip: the server issues a
+// nonce and message, the wallet signs it, and the server verifies it.
+func login(args []string) {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	accNum := fs.Int("account", 2, "account number to sign in with")
+	domain := fs.String("domain", "app.xyz", "domain requesting the sign-in")
+	chainID := fs.Uint64("chain-id", 80002, "chain ID the session is bound to")
+	fs.Parse(args)
+
+	addr, priv := account.GetAccount(*accNum)
+	ctx := context.Background()
+
+	// Server side: issue a nonce and build the message
+	nonces := siwe.NewMemoryNonceStore(10 * time.Minute)
+	nonce, err := nonces.Issue()
+	if err != nil {
+		log.Fatal("Failed to issue nonce:", err)
+	}
+	issuedAt := time.Now().UTC().Truncate(time.Second)
+	expiresAt := issuedAt.Add(10 * time.Minute)
+	msg := &siwe.Message{
+		Scheme:         "https",
+		Domain:         *domain,
+		Address:        *addr,
+		Statement:      "Login to " + *domain,
+		URI:            "https://" + *domain + "/login",
+		Version:        "1",
+		ChainID:        *chainID,
+		Nonce:          nonce,
+		IssuedAt:       issuedAt,
+		ExpirationTime: &expiresAt,
+	}
+
+	// Wallet side: sign the text form
+	signature, err := siwe.Sign(msg, priv)
+	if err != nil {
+		log.Fatal("Failed to sign message:", err)
+	}
+	fmt.Printf("Message:\n%s\n\n", msg)
+	fmt.Printf("Signature: 0x%x\n", signature)
+
+	// Server side: parse what the wallet signed and verify it
+	parsed, err := siwe.ParseMessage(msg.String())
+	if err != nil {
+		log.Fatal("Failed to parse message:", err)
+	}
+	opts := siwe.VerifyOptions{Domain: *domain, ChainID: *chainID, Nonces: nonces}
+	if err := siwe.Verify(ctx, parsed, signature, opts); err != nil {
+		log.Fatal("Login rejected:", err)
+	}
+	fmt.Println("Login accepted for", parsed.Address.Hex())
+
+	// Replaying the same signed message must fail
+	err = siwe.Verify(ctx, parsed, signature, opts)
+	fmt.Println("Replay rejected:", errors.Is(err, siwe.ErrInvalidNonce))
+}
+
+// messageHash hashes message according to the requested EIP-191 version.
+func messageHash(version, validator string, message []byte) common.Hash {
+	v, err := strconv.ParseUint(strings.TrimPrefix(version, "0x"), 16, 8)
+	if err != nil {
+		log.Fatalf("Invalid EIP-191 version: %s", version)
+	}
+	if byte(v) == eip191.VersionIntendedValidator && !common.IsHexAddress(validator) {
+		log.Fatal("Version 00 requires a -validator address")
+	}
+
+	hash, err := eip191.Hash(byte(v), common.HexToAddress(validator), message)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return hash
+}
+
+// parseMessage decodes 0x-prefixed input as hex and keeps anything else as
+// text. Malformed hex is an error rather than silently signing the text, a
+// typo would otherwise sign a different message; text forces the text form.
+func parseMessage(input string, text bool) ([]byte, error) {
+	if text || !strings.HasPrefix(input, "0x") {
+		return []byte(input), nil
+	}
+	b, err := hexutil.Decode(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex message %q (%v), pass -text to use it as text", input, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,249 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"transactiontypes/erc20"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Config is the policy file. Default applies to every sender, an entry in
+// Accounts replaces the default rules it sets for that sender only.
+//
+//	{
+//	  "default": {
+//	    "dailyValueLimit": "0.5 ether",
+//	    "maxFeePerGas": "200 gwei",
+//	    "maxBlobs": 2
+//	  },
+//	  "accounts": {
+//	    "0x8a3c...": {
+//	      "recipients": ["0x..."],
+//	      "contracts": ["0x0fd9e8d3af1aaee056eb9e802c3a762a667b1904"],
+//	      "functions": ["transfer(address,uint256)", "0x095ea7b3"]
+//	    }
+//	  }
+//	}
+type Config struct {
+	Default  Rules                    `json:"default"`
+	Accounts map[common.Address]Rules `json:"accounts,omitempty"`
+}
+
+// Rules limit what a sender may sign. Unset rules don't limit anything,
+// except for AllowAuthorizationSigning.
+type Rules struct {
+	// Total value sent per UTC day, counted from the journal
+	DailyValueLimit *Wei `json:"dailyValueLimit,omitempty"`
+
+	// Caps on the gas price (legacy and access list transactions) or the
+	// fee caps (all others), and on the blob gas price
+	MaxFeePerGas         *Wei `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *Wei `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerBlobGas     *Wei `json:"maxFeePerBlobGas,omitempty"`
+	MaxBlobs             *int `json:"maxBlobs,omitempty"`
+
+	// Recipients may be sent plain transfers. Contracts may be called with
+	// data and delegated to by EIP-7702 authorizations.
+	Recipients []common.Address `json:"recipients,omitempty"`
+	Contracts  []common.Address `json:"contracts,omitempty"`
+	// Functions allowed in calls, as signatures or 4 byte selectors
+	Functions []string `json:"functions,omitempty"`
+
+	AllowContractCreation *bool `json:"allowContractCreation,omitempty"`
+	// EIP-7702 authorizations with chain ID 0 are valid on every chain and
+	// are refused unless this is set
+	AllowAnyChainAuthorizations *bool `json:"allowAnyChainAuthorizations,omitempty"`
+	// Off-chain messages (personal_sign, EIP-712 typed data) are refused if
+	// this is false. A signed permit moves tokens without a transaction.
+	AllowMessageSigning *bool `json:"allowMessageSigning,omitempty"`
+	// EIP-7702 authorizations signed on their own hand the account to the
+	// delegate, past every other rule. Unset, they are only signed for the
+	// contracts allowlist, and refused if there is none.
+	AllowAuthorizationSigning *bool `json:"allowAuthorizationSigning,omitempty"`
+}
+
+// merge returns r with the rules that override sets replaced.
+func (r Rules) merge(override Rules) Rules {
+	if override.DailyValueLimit != nil {
+		r.DailyValueLimit = override.DailyValueLimit
+	}
+	if override.MaxFeePerGas != nil {
+		r.MaxFeePerGas = override.MaxFeePerGas
+	}
+	if override.MaxPriorityFeePerGas != nil {
+		r.MaxPriorityFeePerGas = override.MaxPriorityFeePerGas
+	}
+	if override.MaxFeePerBlobGas != nil {
+		r.MaxFeePerBlobGas = override.MaxFeePerBlobGas
+	}
+	if override.MaxBlobs != nil {
+		r.MaxBlobs = override.MaxBlobs
+	}
+	if override.Recipients != nil {
+		r.Recipients = override.Recipients
+	}
+	if override.Contracts != nil {
+		r.Contracts = override.Contracts
+	}
+	if override.Functions != nil {
+		r.Functions = override.Functions
+	}
+	if override.AllowContractCreation != nil {
+		r.AllowContractCreation = override.AllowContractCreation
+	}
+	if override.AllowAnyChainAuthorizations != nil {
+		r.AllowAnyChainAuthorizations = override.AllowAnyChainAuthorizations
+	}
+	if override.AllowMessageSigning != nil {
+		r.AllowMessageSigning = override.AllowMessageSigning
+	}
+	if override.AllowAuthorizationSigning != nil {
+		r.AllowAuthorizationSigning = override.AllowAuthorizationSigning
+	}
+	return r
+}
+
+// selectors parses Functions.
+func (r Rules) selectors() (map[[4]byte]string, error) {
+	selectors := make(map[[4]byte]string, len(r.Functions))
+	for _, fn := range r.Functions {
+		var selector [4]byte
+		if strings.HasPrefix(fn, "0x") {
+			b, err := hexutil.Decode(fn)
+			if err != nil || len(b) != 4 {
+				return nil, fmt.Errorf("invalid function selector %q", fn)
+			}
+			copy(selector[:], b)
+		} else {
+			if !strings.HasSuffix(fn, ")") || strings.ContainsAny(fn, " ") {
+				return nil, fmt.Errorf("invalid function signature %q, use e.g. transfer(address,uint256)", fn)
+			}
+			copy(selector[:], crypto.Keccak256([]byte(fn)))
+		}
+		selectors[selector] = fn
+	}
+	return selectors, nil
+}
+
+// Wei is an amount of ether in the policy file: a number of wei, or a decimal
+// followed by a unit, "wei", "gwei" or "ether".
+type Wei big.Int
+
+var units = map[string]uint8{"wei": 0, "gwei": 9, "ether": 18}
+
+// ParseWei parses an amount such as "0.5 ether", "30 gwei" or "1000".
+func ParseWei(s string) (*big.Int, error) {
+	amount, unit, ok := strings.Cut(strings.TrimSpace(s), " ")
+	decimals := uint8(0)
+	if ok {
+		var known bool
+		if decimals, known = units[strings.ToLower(strings.TrimSpace(unit))]; !known {
+			return nil, fmt.Errorf("unknown unit in %q, use wei, gwei or ether", s)
+		}
+	}
+	return erc20.ParseUnits(amount, decimals)
+}
+
+// FormatWei renders v in ether.
+func FormatWei(v *big.Int) string {
+	return erc20.FormatUnits(v, 18) + " ether"
+}
+
+func (w *Wei) Int() *big.Int {
+	return (*big.Int)(w)
+}
+
+func (w *Wei) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		// Plain numbers are wei
+		s = string(input)
+	}
+	v, err := ParseWei(s)
+	if err != nil {
+		return err
+	}
+	*w = Wei(*v)
+	return nil
+}
+
+func (w *Wei) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.Int().String())
+}
+
+// DefaultPath is $TX_POLICY, or ~/.transactiontypes/policy.json.
+func DefaultPath() string {
+	if path := os.Getenv("TX_POLICY"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".transactiontypes", "policy.json")
+}
+
+// Load reads and validates a policy file. Unknown fields are an error, a
+// misspelt rule would otherwise silently not apply.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := new(Config)
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadDefault loads the policy at DefaultPath. Without a policy file nothing
+// is restricted.
+func LoadDefault() (*Config, error) {
+	cfg, err := Load(DefaultPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return new(Config), nil
+	}
+	return cfg, err
+}
+
+func (c *Config) validate() error {
+	if _, err := c.Default.selectors(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for addr, rules := range c.Accounts {
+		if _, err := rules.selectors(); err != nil {
+			return fmt.Errorf("account %s: %w", addr.Hex(), err)
+		}
+		if rules.MaxBlobs != nil && *rules.MaxBlobs < 0 {
+			return fmt.Errorf("account %s: maxBlobs is negative", addr.Hex())
+		}
+	}
+	if c.Default.MaxBlobs != nil && *c.Default.MaxBlobs < 0 {
+		return errors.New("default: maxBlobs is negative")
+	}
+	return nil
+}
+
+// Rules returns the rules for from.
+func (c *Config) Rules(from common.Address) Rules {
+	if override, ok := c.Accounts[from]; ok {
+		return c.Default.merge(override)
+	}
+	return c.Default
+}
@@ -0,0 +1,258 @@
+// Package policy checks transactions against spending rules before they are
+// signed: daily value limits per sender, fee caps, recipient, contract and
+// function allowlists, EIP-7702 authorizations valid on every chain and blob
+// counts. Off-chain messages can be refused, and typed data is held to the
+// contract allowlist. The rules come from a JSON file, see Config.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+	"transactiontypes/builder"
+	"transactiontypes/erc20"
+	"transactiontypes/journal"
+	"transactiontypes/replay"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrRejected matches every *Rejection with errors.Is.
+var ErrRejected = errors.New("rejected by spending policy")
+
+// Violation is one broken rule.
+type Violation struct {
+	Rule   string // the rule's name in the policy file
+	Reason string
+}
+
+func (v Violation) String() string {
+	return v.Rule + ": " + v.Reason
+}
+
+// Rejection is returned by Check with every rule the request breaks.
+type Rejection struct {
+	From       common.Address
+	Violations []Violation
+}
+
+func (r *Rejection) Error() string {
+	reasons := make([]string, len(r.Violations))
+	for i, v := range r.Violations {
+		reasons[i] = v.String()
+	}
+	return fmt.Sprintf("%v for %s: %s", ErrRejected, r.From.Hex(), strings.Join(reasons, "; "))
+}
+
+func (r *Rejection) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Ledger reports how much a sender has already sent.
+type Ledger interface {
+	Spent(from common.Address, since time.Time) (*big.Int, error)
+}
+
+// JournalLedger counts the value of journaled transactions. Only unsigned,
+// rejected and replaced ones are left out: a dropped or reorged transaction
+// can still be mined.
+type JournalLedger struct {
+	Journal *journal.Journal
+}
+
+func (l JournalLedger) Spent(from common.Address, since time.Time) (*big.Int, error) {
+	records, err := l.Journal.Records()
+	if err != nil {
+		return nil, err
+	}
+	spent := new(big.Int)
+	for _, r := range records {
+		if r.From == nil || *r.From != from || r.Value == nil || r.FirstSeen.Before(since) {
+			continue
+		}
+		if r.Status == journal.StatusBuilt || r.Status == journal.StatusRejected || r.Status == journal.StatusReplaced {
+			continue
+		}
+		spent.Add(spent, r.Value.ToInt())
+	}
+	return spent, nil
+}
+
+// Policy enforces a Config.
+type Policy struct {
+	config *Config
+	ledger Ledger
+}
+
+// New returns a policy enforcing config. Daily limits need a ledger; with a
+// nil ledger a policy that sets one rejects everything it applies to.
+func New(config *Config, ledger Ledger) *Policy {
+	return &Policy{config: config, ledger: ledger}
+}
+
+// Default loads the policy at DefaultPath, counting daily spending from the
+// default journal.
+func Default() (*Policy, error) {
+	cfg, err := LoadDefault()
+	if err != nil {
+		return nil, err
+	}
+	j, err := journal.OpenDefault()
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg, JournalLedger{j}), nil
+}
+
+// Check returns a *Rejection listing every rule req breaks, or nil.
+func (p *Policy) Check(req *builder.Request) error {
+	rules := p.config.Rules(req.From)
+	var violations []Violation
+	reject := func(rule, format string, args ...any) {
+		violations = append(violations, Violation{rule, fmt.Sprintf(format, args...)})
+	}
+
+	// Value
+	if limit := rules.DailyValueLimit; limit != nil && req.Value != nil && req.Value.Sign() > 0 {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		if p.ledger == nil {
+			reject("dailyValueLimit", "no ledger to count today's spending")
+		} else if spent, err := p.ledger.Spent(req.From, today); err != nil {
+			reject("dailyValueLimit", "failed to count today's spending: %v", err)
+		} else if total := new(big.Int).Add(spent, req.Value); total.Cmp(limit.Int()) > 0 {
+			reject("dailyValueLimit", "sending %s brings today's total to %s, the limit is %s",
+				FormatWei(req.Value), FormatWei(total), FormatWei(limit.Int()))
+		}
+	}
+
+	// Fees
+	feeCap := req.GasFeeCap
+	if req.Type == types.LegacyTxType || req.Type == types.AccessListTxType {
+		feeCap = req.GasPrice
+	}
+	if max := rules.MaxFeePerGas; max != nil && feeCap != nil && feeCap.Cmp(max.Int()) > 0 {
+		reject("maxFeePerGas", "%s gwei is above the cap of %s gwei", gwei(feeCap), gwei(max.Int()))
+	}
+	if max := rules.MaxPriorityFeePerGas; max != nil && req.GasTipCap != nil && req.GasTipCap.Cmp(max.Int()) > 0 {
+		reject("maxPriorityFeePerGas", "%s gwei is above the cap of %s gwei", gwei(req.GasTipCap), gwei(max.Int()))
+	}
+	if max := rules.MaxFeePerBlobGas; max != nil && req.BlobFeeCap != nil && req.BlobFeeCap.Cmp(max.Int()) > 0 {
+		reject("maxFeePerBlobGas", "%s gwei is above the cap of %s gwei", gwei(req.BlobFeeCap), gwei(max.Int()))
+	}
+	if max := rules.MaxBlobs; max != nil && len(req.BlobHashes) > *max {
+		reject("maxBlobs", "%d blobs, at most %d allowed", len(req.BlobHashes), *max)
+	}
+
+	// Destination
+	switch {
+	case req.IsCreate():
+		if rules.AllowContractCreation != nil && !*rules.AllowContractCreation {
+			reject("allowContractCreation", "contract creation is not allowed")
+		}
+	case len(req.Data) == 0:
+		if rules.Recipients != nil && !slices.Contains(rules.Recipients, *req.To) {
+			reject("recipients", "%s is not an allowed recipient", req.To.Hex())
+		}
+	default:
+		if rules.Contracts != nil && !slices.Contains(rules.Contracts, *req.To) {
+			reject("contracts", "%s is not an allowed contract", req.To.Hex())
+		}
+		if rules.Functions != nil {
+			// Validated when loading
+			selectors, _ := rules.selectors()
+			if len(req.Data) < 4 {
+				reject("functions", "%d bytes of call data is not a function call", len(req.Data))
+			} else if _, ok := selectors[[4]byte(req.Data[:4])]; !ok {
+				reject("functions", "selector %#x is not an allowed function", req.Data[:4])
+			}
+		}
+	}
+
+	violations = append(violations, checkAuthorizations(rules, req.AuthList, req.ChainID)...)
+	if len(violations) > 0 {
+		return &Rejection{From: req.From, Violations: violations}
+	}
+	return nil
+}
+
+// CheckAuthorization checks an EIP-7702 authorization that authority is
+// asked to sign on its own, outside a transaction. Unlike everything else
+// this fails closed: without a contracts allowlist it needs
+// allowAuthorizationSigning.
+func (p *Policy) CheckAuthorization(authority common.Address, auth types.SetCodeAuthorization) error {
+	rules := p.config.Rules(authority)
+	var violations []Violation
+	if allow := rules.AllowAuthorizationSigning; allow != nil && !*allow || allow == nil && rules.Contracts == nil {
+		violations = append(violations, Violation{"allowAuthorizationSigning", "signing authorizations is not allowed without a contracts allowlist"})
+	}
+	violations = append(violations, checkAuthorizations(rules, []types.SetCodeAuthorization{auth}, auth.ChainID.ToBig())...)
+	if len(violations) > 0 {
+		return &Rejection{From: authority, Violations: violations}
+	}
+	return nil
+}
+
+// CheckMessage checks a plain message, personal_sign, that from is asked to
+// sign.
+func (p *Policy) CheckMessage(from common.Address) error {
+	if violations := checkMessage(p.config.Rules(from)); len(violations) > 0 {
+		return &Rejection{From: from, Violations: violations}
+	}
+	return nil
+}
+
+// CheckTypedData checks EIP-712 typed data from is asked to sign, for the
+// verifyingContract of its domain, nil if it names none. The contracts
+// allowlist applies to it: a permit signed for a token is as good as an
+// approval sent to it.
+func (p *Policy) CheckTypedData(from common.Address, verifyingContract *common.Address) error {
+	rules := p.config.Rules(from)
+	violations := checkMessage(rules)
+	if rules.Contracts != nil {
+		switch {
+		case verifyingContract == nil:
+			violations = append(violations, Violation{"contracts", "typed data without verifyingContract cannot be checked against the allowed contracts"})
+		case !slices.Contains(rules.Contracts, *verifyingContract):
+			violations = append(violations, Violation{"contracts", fmt.Sprintf("typed data for %s, which is not an allowed contract", verifyingContract.Hex())})
+		}
+	}
+	if len(violations) > 0 {
+		return &Rejection{From: from, Violations: violations}
+	}
+	return nil
+}
+
+func checkMessage(rules Rules) []Violation {
+	if rules.AllowMessageSigning != nil && !*rules.AllowMessageSigning {
+		return []Violation{{"allowMessageSigning", "signing off-chain messages is not allowed"}}
+	}
+	return nil
+}
+
+// checkAuthorizations applies the delegation rules to the authorizations of
+// a transaction on chainID.
+func checkAuthorizations(rules Rules, auths []types.SetCodeAuthorization, chainID *big.Int) []Violation {
+	var violations []Violation
+	for i, auth := range auths {
+		if rules.Contracts != nil && !slices.Contains(rules.Contracts, auth.Address) {
+			violations = append(violations, Violation{"contracts",
+				fmt.Sprintf("authorization %d delegates to %s, which is not an allowed contract", i, auth.Address.Hex())})
+		}
+	}
+	if rules.AllowAnyChainAuthorizations == nil || !*rules.AllowAnyChainAuthorizations {
+		for _, finding := range replay.CheckAuthorizations(auths, chainID) {
+			if finding.Risk == replay.RiskHigh {
+				violations = append(violations, Violation{"allowAnyChainAuthorizations", finding.Subject + ": " + finding.Message})
+			}
+		}
+	}
+	return violations
+}
+
+func gwei(v *big.Int) string {
+	return erc20.FormatUnits(v, 9)
+}
@@ -0,0 +1,384 @@
+package policy
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"transactiontypes/builder"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+var (
+	sender    = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	trusted   = common.HexToAddress("0x00000000000000000000000000000000000000a2")
+	friend    = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	stranger  = common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	token     = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	unknownSC = common.HexToAddress("0x00000000000000000000000000000000000000c2")
+
+	transferSelector = crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]
+	approveSelector  = crypto.Keccak256([]byte("approve(address,uint256)"))[:4]
+)
+
+// fakeLedger reports a fixed amount already spent per sender.
+type fakeLedger struct {
+	spent map[common.Address]*big.Int
+	err   error
+}
+
+func (l fakeLedger) Spent(from common.Address, since time.Time) (*big.Int, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	if v, ok := l.spent[from]; ok {
+		return new(big.Int).Set(v), nil
+	}
+	return new(big.Int), nil
+}
+
+func loadConfig(t *testing.T, policy string) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func wei(t *testing.T, s string) *big.Int {
+	t.Helper()
+	v, err := ParseWei(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// violated returns the rule names of a Check result, nil if it passed.
+func violated(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		t.Fatalf("error %v is not a *Rejection", err)
+	}
+	if !errors.Is(err, ErrRejected) {
+		t.Fatalf("rejection %v does not match ErrRejected", err)
+	}
+	rules := make([]string, len(rejection.Violations))
+	for i, v := range rejection.Violations {
+		rules[i] = v.Rule
+	}
+	return rules
+}
+
+func TestCheck(t *testing.T) {
+	transfer := func(to common.Address, value string) *builder.Request {
+		return &builder.Request{Type: types.DynamicFeeTxType, ChainID: big.NewInt(1), From: sender, To: &to, Value: wei(t, value)}
+	}
+	call := func(to common.Address, data []byte) *builder.Request {
+		return &builder.Request{Type: types.DynamicFeeTxType, ChainID: big.NewInt(1), From: sender, To: &to, Data: data}
+	}
+	delegate := func(chainID uint64, to common.Address) *builder.Request {
+		req := call(sender, nil)
+		req.Type = types.SetCodeTxType
+		req.AuthList = []types.SetCodeAuthorization{{ChainID: *uint256.NewInt(chainID), Address: to, Nonce: 1}}
+		return req
+	}
+	ledger := fakeLedger{spent: map[common.Address]*big.Int{sender: wei(t, "0.4 ether")}}
+
+	tests := []struct {
+		name   string
+		policy string
+		ledger Ledger
+		req    *builder.Request
+		want   []string
+	}{
+		{
+			name:   "no policy",
+			policy: `{}`,
+			req:    transfer(stranger, "100 ether"),
+		},
+		{
+			name:   "within the daily limit",
+			policy: `{"default": {"dailyValueLimit": "0.5 ether"}}`,
+			ledger: ledger,
+			req:    transfer(stranger, "0.1 ether"),
+		},
+		{
+			name:   "above the daily limit",
+			policy: `{"default": {"dailyValueLimit": "0.5 ether"}}`,
+			ledger: ledger,
+			req:    transfer(stranger, "0.2 ether"),
+			want:   []string{"dailyValueLimit"},
+		},
+		{
+			name:   "daily limit without a ledger",
+			policy: `{"default": {"dailyValueLimit": "0.5 ether"}}`,
+			req:    transfer(stranger, "1 wei"),
+			want:   []string{"dailyValueLimit"},
+		},
+		{
+			name:   "daily limit with a failing ledger",
+			policy: `{"default": {"dailyValueLimit": "0.5 ether"}}`,
+			ledger: fakeLedger{err: errors.New("journal unreadable")},
+			req:    transfer(stranger, "1 wei"),
+			want:   []string{"dailyValueLimit"},
+		},
+		{
+			name:   "daily limit raised for one account",
+			policy: `{"default": {"dailyValueLimit": "0.5 ether"}, "accounts": {"` + sender.Hex() + `": {"dailyValueLimit": "1 ether"}}}`,
+			ledger: ledger,
+			req:    transfer(stranger, "0.5 ether"),
+		},
+		{
+			name:   "fee cap",
+			policy: `{"default": {"maxFeePerGas": "200 gwei"}}`,
+			req: &builder.Request{Type: types.DynamicFeeTxType, From: sender, To: &stranger,
+				GasFeeCap: wei(t, "201 gwei"), GasTipCap: wei(t, "1 gwei")},
+			want: []string{"maxFeePerGas"},
+		},
+		{
+			name:   "fee cap on the legacy gas price",
+			policy: `{"default": {"maxFeePerGas": "200 gwei"}}`,
+			req:    &builder.Request{Type: types.LegacyTxType, From: sender, To: &stranger, GasPrice: wei(t, "300 gwei")},
+			want:   []string{"maxFeePerGas"},
+		},
+		{
+			name:   "tip cap",
+			policy: `{"default": {"maxFeePerGas": "200 gwei", "maxPriorityFeePerGas": "2 gwei"}}`,
+			req: &builder.Request{Type: types.DynamicFeeTxType, From: sender, To: &stranger,
+				GasFeeCap: wei(t, "100 gwei"), GasTipCap: wei(t, "3 gwei")},
+			want: []string{"maxPriorityFeePerGas"},
+		},
+		{
+			name:   "blob fee cap and count",
+			policy: `{"default": {"maxFeePerBlobGas": "10 gwei", "maxBlobs": 2}}`,
+			req: &builder.Request{Type: types.BlobTxType, From: sender, To: &stranger,
+				BlobFeeCap: wei(t, "11 gwei"), BlobHashes: make([]common.Hash, 3)},
+			want: []string{"maxFeePerBlobGas", "maxBlobs"},
+		},
+		{
+			name:   "blob count within the limit",
+			policy: `{"default": {"maxBlobs": 2}}`,
+			req:    &builder.Request{Type: types.BlobTxType, From: sender, To: &stranger, BlobHashes: make([]common.Hash, 2)},
+		},
+		{
+			name:   "allowed recipient",
+			policy: `{"default": {"recipients": ["` + friend.Hex() + `"]}}`,
+			req:    transfer(friend, "1 ether"),
+		},
+		{
+			name:   "recipient not allowed",
+			policy: `{"default": {"recipients": ["` + friend.Hex() + `"]}}`,
+			req:    transfer(stranger, "1 ether"),
+			want:   []string{"recipients"},
+		},
+		{
+			name:   "contract not allowed",
+			policy: `{"default": {"contracts": ["` + token.Hex() + `"]}}`,
+			req:    call(unknownSC, transferSelector),
+			want:   []string{"contracts"},
+		},
+		{
+			name:   "allowed function by signature",
+			policy: `{"default": {"contracts": ["` + token.Hex() + `"], "functions": ["transfer(address,uint256)"]}}`,
+			req:    call(token, append(transferSelector, make([]byte, 64)...)),
+		},
+		{
+			name:   "allowed function by selector",
+			policy: `{"default": {"functions": ["0x095ea7b3"]}}`,
+			req:    call(token, approveSelector),
+		},
+		{
+			name:   "function not allowed",
+			policy: `{"default": {"functions": ["transfer(address,uint256)"]}}`,
+			req:    call(token, approveSelector),
+			want:   []string{"functions"},
+		},
+		{
+			name:   "call data too short for a selector",
+			policy: `{"default": {"functions": ["transfer(address,uint256)"]}}`,
+			req:    call(token, []byte{0xa9, 0x05}),
+			want:   []string{"functions"},
+		},
+		{
+			name:   "contract creation not allowed",
+			policy: `{"default": {"allowContractCreation": false}}`,
+			req:    &builder.Request{Type: types.DynamicFeeTxType, From: sender, Data: []byte{0x60, 0x00}},
+			want:   []string{"allowContractCreation"},
+		},
+		{
+			name:   "authorization on this chain",
+			policy: `{}`,
+			req:    delegate(1, token),
+		},
+		{
+			name:   "authorization on every chain",
+			policy: `{}`,
+			req:    delegate(0, token),
+			want:   []string{"allowAnyChainAuthorizations"},
+		},
+		{
+			name:   "authorization on every chain explicitly allowed",
+			policy: `{"default": {"allowAnyChainAuthorizations": true}}`,
+			req:    delegate(0, token),
+		},
+		{
+			name:   "authorization to a contract not allowed",
+			policy: `{"default": {"contracts": ["` + token.Hex() + `"]}}`,
+			req:    delegate(1, unknownSC),
+			want:   []string{"contracts"},
+		},
+		{
+			name:   "every broken rule is reported",
+			policy: `{"default": {"dailyValueLimit": "0.5 ether", "maxFeePerGas": "200 gwei", "recipients": ["` + friend.Hex() + `"]}}`,
+			ledger: ledger,
+			req: &builder.Request{Type: types.DynamicFeeTxType, From: sender, To: &stranger,
+				Value: wei(t, "1 ether"), GasFeeCap: wei(t, "300 gwei")},
+			want: []string{"dailyValueLimit", "maxFeePerGas", "recipients"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(loadConfig(t, tt.policy), tt.ledger)
+			got := violated(t, p.Check(tt.req))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violated %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAuthorization(t *testing.T) {
+	p := New(loadConfig(t, `{
+		"default": {"contracts": ["`+token.Hex()+`"]},
+		"accounts": {"`+trusted.Hex()+`": {"allowAnyChainAuthorizations": true}}
+	}`), nil)
+	anyChain := types.SetCodeAuthorization{Address: token, Nonce: 3}
+
+	if got := violated(t, p.CheckAuthorization(sender, anyChain)); !slices.Equal(got, []string{"allowAnyChainAuthorizations"}) {
+		t.Errorf("violated %v, want allowAnyChainAuthorizations", got)
+	}
+	if err := p.CheckAuthorization(trusted, anyChain); err != nil {
+		t.Errorf("account allowed to sign chain ID 0 authorizations: %v", err)
+	}
+	anyChain.ChainID = *uint256.NewInt(1)
+	if err := p.CheckAuthorization(sender, anyChain); err != nil {
+		t.Errorf("authorization bound to a chain: %v", err)
+	}
+}
+
+// Standalone authorizations are refused unless the policy says who to
+// delegate to, or allows them outright.
+func TestCheckAuthorizationFailsClosed(t *testing.T) {
+	auth := types.SetCodeAuthorization{ChainID: *uint256.NewInt(1), Address: unknownSC, Nonce: 3}
+	tests := []struct {
+		name   string
+		policy string
+		want   []string
+	}{
+		{"no policy", `{}`, []string{"allowAuthorizationSigning"}},
+		{"other contract", `{"default": {"contracts": ["` + token.Hex() + `"]}}`, []string{"contracts"}},
+		{"allowed", `{"default": {"allowAuthorizationSigning": true}}`, nil},
+		{"disallowed", `{"default": {"contracts": ["` + unknownSC.Hex() + `"], "allowAuthorizationSigning": false}}`, []string{"allowAuthorizationSigning"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(loadConfig(t, tt.policy), nil)
+			if got := violated(t, p.CheckAuthorization(sender, auth)); !slices.Equal(got, tt.want) {
+				t.Errorf("violated %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMessages(t *testing.T) {
+	p := New(loadConfig(t, `{
+		"default": {"contracts": ["`+token.Hex()+`"]},
+		"accounts": {"`+trusted.Hex()+`": {"allowMessageSigning": false}}
+	}`), nil)
+	tests := []struct {
+		name     string
+		from     common.Address
+		contract *common.Address
+		want     []string
+	}{
+		{"allowed contract", sender, &token, nil},
+		{"other contract", sender, &unknownSC, []string{"contracts"}},
+		{"no verifyingContract", sender, nil, []string{"contracts"}},
+		{"signing disabled", trusted, &token, []string{"allowMessageSigning"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violated(t, p.CheckTypedData(tt.from, tt.contract)); !slices.Equal(got, tt.want) {
+				t.Errorf("violated %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := p.CheckMessage(sender); err != nil {
+		t.Errorf("message signing allowed by default: %v", err)
+	}
+	if got := violated(t, p.CheckMessage(trusted)); !slices.Equal(got, []string{"allowMessageSigning"}) {
+		t.Errorf("violated %v, want allowMessageSigning", got)
+	}
+}
+
+func TestLoadRejectsInvalidPolicies(t *testing.T) {
+	tests := map[string]string{
+		"unknown rule":       `{"default": {"dailyLimit": "1 ether"}}`,
+		"unknown unit":       `{"default": {"maxFeePerGas": "3 finney"}}`,
+		"bad selector":       `{"default": {"functions": ["0x1234"]}}`,
+		"bad signature":      `{"default": {"functions": ["transfer"]}}`,
+		"negative blobs":     `{"default": {"maxBlobs": -1}}`,
+		"bad account policy": `{"accounts": {"` + sender.Hex() + `": {"functions": ["transfer(address, uint256)"]}}}`,
+	}
+	for name, policy := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "invalid policy") {
+				t.Fatalf("err = %v, want an invalid policy", err)
+			}
+		})
+	}
+}
+
+func TestParseWei(t *testing.T) {
+	tests := map[string]string{
+		"1000":        "1000",
+		"30 gwei":     "30000000000",
+		"0.5 ether":   "500000000000000000",
+		"1.5 Gwei":    "1500000000",
+		"  2 ether  ": "2000000000000000000",
+	}
+	for input, want := range tests {
+		got, err := ParseWei(input)
+		if err != nil || got.String() != want {
+			t.Errorf("ParseWei(%q) = %v, %v, want %s", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "1 wei wei", "0.1 wei", "-1 ether", "ten ether"} {
+		if v, err := ParseWei(input); err == nil {
+			t.Errorf("ParseWei(%q) = %v, want an error", input, v)
+		}
+	}
+}
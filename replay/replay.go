@@ -0,0 +1,179 @@
+// Package replay reports on which chains a signed transaction, and the
+// EIP-7702 authorizations it carries, could be executed.
+package replay
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"transactiontypes/builder"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Risk grades a finding.
+type Risk int
+
+const (
+	RiskInfo   Risk = iota // bound to a single chain
+	RiskMedium             // unusual, but not replayable elsewhere
+	RiskHigh               // valid on every chain
+)
+
+func (r Risk) String() string {
+	switch r {
+	case RiskHigh:
+		return "HIGH"
+	case RiskMedium:
+		return "MEDIUM"
+	default:
+		return "info"
+	}
+}
+
+// Finding is one observation about a transaction or authorization.
+type Finding struct {
+	Risk    Risk
+	Subject string // "transaction" or "authorization N"
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", f.Risk, f.Subject, f.Message)
+}
+
+// Report is the result of Analyze.
+type Report struct {
+	Hash   common.Hash
+	Type   uint8
+	Sender common.Address
+
+	// AnyChain is set for unprotected transactions, which every chain
+	// accepts. Otherwise ChainID is the only chain the transaction is valid on.
+	AnyChain bool
+	ChainID  *big.Int
+
+	Findings []Finding
+}
+
+// MaxRisk returns the highest risk among the findings.
+func (r *Report) MaxRisk() Risk {
+	max := RiskInfo
+	for _, f := range r.Findings {
+		if f.Risk > max {
+			max = f.Risk
+		}
+	}
+	return max
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Transaction %s (type %d) from %s\n", r.Hash.Hex(), r.Type, r.Sender.Hex())
+	if r.AnyChain {
+		b.WriteString("Valid on: every chain where the sender has this nonce\n")
+	} else {
+		fmt.Fprintf(&b, "Valid on: %s only\n", ChainName(r.ChainID))
+	}
+	for _, f := range r.Findings {
+		fmt.Fprintln(&b, " ", f)
+	}
+	return b.String()
+}
+
+// Analyze reports the chains tx is valid on and checks its authorizations.
+func Analyze(tx *types.Transaction) (*Report, error) {
+	report := &Report{Hash: tx.Hash(), Type: tx.Type()}
+
+	// Legacy transactions encode the chain ID in v (EIP-155: v = 35/36 +
+	// 2*chainID). v = 27/28 means no chain ID was signed at all.
+	if tx.Protected() {
+		report.ChainID = tx.ChainId()
+	} else {
+		report.AnyChain = true
+	}
+	sender, err := builder.Sender(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover sender: %w", err)
+	}
+	report.Sender = sender
+
+	switch {
+	case report.AnyChain:
+		report.Findings = append(report.Findings, Finding{RiskHigh, "transaction",
+			fmt.Sprintf("unprotected (pre-EIP-155) signature, anyone can replay it on every chain where %s has nonce %d and enough funds", sender.Hex(), tx.Nonce())})
+	case report.ChainID.Sign() == 0:
+		// Typed transactions always sign a chain ID, 0 matches no public chain
+		report.Findings = append(report.Findings, Finding{RiskMedium, "transaction",
+			"signed for chain ID 0, which no public network uses"})
+	case tx.Type() == types.LegacyTxType:
+		report.Findings = append(report.Findings, Finding{RiskInfo, "transaction",
+			fmt.Sprintf("EIP-155 protected for %s", ChainName(report.ChainID))})
+	default:
+		report.Findings = append(report.Findings, Finding{RiskInfo, "transaction",
+			fmt.Sprintf("type %d transaction bound to %s", tx.Type(), ChainName(report.ChainID))})
+	}
+
+	report.Findings = append(report.Findings, CheckAuthorizations(tx.SetCodeAuthorizations(), report.ChainID)...)
+	return report, nil
+}
+
+// CheckAuthorizations inspects EIP-7702 authorizations, signed or not, for a
+// transaction on chainID. An authorization with chain ID 0 is valid on every
+// chain: anyone holding it can delegate the authority's account wherever its
+// nonce matches, so it is flagged as high risk.
+func CheckAuthorizations(auths []types.SetCodeAuthorization, chainID *big.Int) []Finding {
+	var findings []Finding
+	for i, auth := range auths {
+		subject := fmt.Sprintf("authorization %d", i)
+		authority := "the authority"
+		// Unsigned authorizations are checked before signing, there is no
+		// authority to recover yet
+		if auth.R.Sign() != 0 || auth.S.Sign() != 0 {
+			addr, err := auth.Authority()
+			if err != nil {
+				findings = append(findings, Finding{RiskMedium, subject, fmt.Sprintf("invalid signature, it will be skipped: %v", err)})
+				continue
+			}
+			authority = addr.Hex()
+		}
+
+		authChain := auth.ChainID.ToBig()
+		switch {
+		case authChain.Sign() == 0:
+			findings = append(findings, Finding{RiskHigh, subject,
+				fmt.Sprintf("chain ID 0 delegates %s to %s on every chain where its nonce is %d", authority, auth.Address.Hex(), auth.Nonce)})
+		case chainID != nil && authChain.Cmp(chainID) != 0:
+			findings = append(findings, Finding{RiskMedium, subject,
+				fmt.Sprintf("signed for %s, it is skipped on %s but stays valid there", ChainName(authChain), ChainName(chainID))})
+		default:
+			findings = append(findings, Finding{RiskInfo, subject,
+				fmt.Sprintf("delegates %s to %s on %s at nonce %d", authority, auth.Address.Hex(), ChainName(authChain), auth.Nonce)})
+		}
+	}
+	return findings
+}
+
+// chainNames names the networks the examples in this repository use.
+var chainNames = map[uint64]string{
+	1:        "Ethereum mainnet",
+	137:      "Polygon PoS",
+	17000:    "Holesky",
+	80002:    "Polygon Amoy",
+	560048:   "Hoodi",
+	11155111: "Sepolia",
+}
+
+// ChainName returns "chain <id>", with the network name if it is known.
+func ChainName(id *big.Int) string {
+	if id == nil {
+		return "any chain"
+	}
+	if id.IsUint64() {
+		if name, ok := chainNames[id.Uint64()]; ok {
+			return fmt.Sprintf("chain %s (%s)", id, name)
+		}
+	}
+	return "chain " + id.String()
+}
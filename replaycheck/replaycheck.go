@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"transactiontypes/replay"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const usage = `Usage:
+  replaycheck <raw signed tx>
+
+Reports which chains the transaction is valid on and checks its EIP-7702
+authorizations. Exits with status 1 if anything is replayable across chains.`
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(common.FromHex(os.Args[1])); err != nil {
+		log.Fatal("Failed to decode transaction:", err)
+	}
+	report, err := replay.Analyze(tx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(report)
+
+	if report.MaxRisk() == replay.RiskHigh {
+		os.Exit(1)
+	}
+}
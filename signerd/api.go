@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"transactiontypes/builder"
+	"transactiontypes/eip191"
+	"transactiontypes/journal"
+	"transactiontypes/policy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/holiman/uint256"
+)
+
+// Error codes, from EIP-1193 where one fits
+const (
+	codeRejected     = 4001 // refused by the spending policy
+	codeUnauthorized = 4100 // account not managed by this service
+	codeInvalid      = -32602
+)
+
+type signError struct {
+	code int
+	err  error
+	data any
+}
+
+func (e *signError) Error() string  { return e.err.Error() }
+func (e *signError) ErrorCode() int { return e.code }
+func (e *signError) ErrorData() any { return e.data }
+func (e *signError) Unwrap() error  { return e.err }
+
+func invalid(format string, args ...any) error {
+	return &signError{code: codeInvalid, err: fmt.Errorf(format, args...)}
+}
+
+// signer holds the keys and everything a signature has to go through.
+type signer struct {
+	keys    map[common.Address]*ecdsa.PrivateKey
+	policy  *policy.Policy
+	journal *journal.Journal // signed transactions count against daily limits
+	audit   *auditLog
+
+	// Requests are served concurrently. A transaction is checked against the
+	// daily limit, audited and journaled under spendMu, or parallel requests
+	// would all see the same total.
+	spendMu sync.Mutex
+}
+
+func (s *signer) key(from common.Address) (*ecdsa.PrivateKey, error) {
+	key, ok := s.keys[from]
+	if !ok {
+		return nil, &signError{code: codeUnauthorized, err: fmt.Errorf("account %s is not managed by this signer", from.Hex())}
+	}
+	return key, nil
+}
+
+// record audits the outcome of a request. A signature is only returned once
+// it is audited.
+func (s *signer) record(ctx context.Context, method string, from common.Address, hash *common.Hash, err error) error {
+	entry := auditEntry{
+		Remote:   rpc.PeerInfoFromContext(ctx).RemoteAddr,
+		Method:   method,
+		From:     &from,
+		Decision: decisionSigned,
+		Hash:     hash,
+	}
+	if err != nil {
+		entry.Decision, entry.Reason = decisionFailed, err.Error()
+		if errors.Is(err, policy.ErrRejected) {
+			entry.Decision = decisionRejected
+		}
+	}
+	if auditErr := s.audit.write(entry); auditErr != nil {
+		return fmt.Errorf("not signed: %w", auditErr)
+	}
+	return err
+}
+
+// rejected turns a policy rejection into an RPC error listing the violations.
+func rejected(err error) error {
+	var rejection *policy.Rejection
+	if errors.As(err, &rejection) {
+		return &signError{code: codeRejected, err: err, data: rejection.Violations}
+	}
+	return err
+}
+
+// ethAPI is served in the eth namespace.
+type ethAPI struct{ *signer }
+
+// Accounts lists the managed accounts.
+func (api *ethAPI) Accounts() []common.Address {
+	accounts := make([]common.Address, 0, len(api.keys))
+	for addr := range api.keys {
+		accounts = append(accounts, addr)
+	}
+	return accounts
+}
+
+// txArgs are the eth_signTransaction parameters. The signer has no node, so
+// chain ID, nonce, gas and fees must all be given.
+type txArgs struct {
+	Type                 *hexutil.Uint64              `json:"type"`
+	ChainID              *hexutil.Big                 `json:"chainId"`
+	From                 common.Address               `json:"from"`
+	To                   *common.Address              `json:"to"`
+	Nonce                *hexutil.Uint64              `json:"nonce"`
+	Gas                  *hexutil.Uint64              `json:"gas"`
+	GasPrice             *hexutil.Big                 `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big                 `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big                 `json:"maxPriorityFeePerGas"`
+	MaxFeePerBlobGas     *hexutil.Big                 `json:"maxFeePerBlobGas"`
+	Value                *hexutil.Big                 `json:"value"`
+	Data                 *hexutil.Bytes               `json:"data"`
+	Input                *hexutil.Bytes               `json:"input"`
+	AccessList           *types.AccessList            `json:"accessList"`
+	BlobHashes           []common.Hash                `json:"blobVersionedHashes"`
+	AuthorizationList    []types.SetCodeAuthorization `json:"authorizationList"`
+}
+
+// request validates the arguments and turns them into a builder request.
+func (args *txArgs) request() (*builder.Request, error) {
+	if args.ChainID == nil || args.ChainID.ToInt().Sign() <= 0 {
+		return nil, invalid("chainId is required, unprotected transactions are not signed")
+	}
+	if args.Nonce == nil || args.Gas == nil {
+		return nil, invalid("nonce and gas are required")
+	}
+	if args.Data != nil && args.Input != nil && !bytes.Equal(*args.Data, *args.Input) {
+		return nil, invalid("data and input differ")
+	}
+
+	req := &builder.Request{
+		ChainID:    args.ChainID.ToInt(),
+		From:       args.From,
+		To:         args.To,
+		Nonce:      uint64(*args.Nonce),
+		Gas:        uint64(*args.Gas),
+		Value:      args.Value.ToInt(),
+		BlobHashes: args.BlobHashes,
+		AuthList:   args.AuthorizationList,
+	}
+	if args.Input != nil {
+		req.Data = *args.Input
+	} else if args.Data != nil {
+		req.Data = *args.Data
+	}
+	if args.AccessList != nil {
+		req.AccessList = *args.AccessList
+	}
+
+	// The type follows from the fields if it isn't given
+	switch {
+	case args.Type != nil:
+		req.Type = uint8(*args.Type)
+	case len(args.AuthorizationList) > 0:
+		req.Type = types.SetCodeTxType
+	case len(args.BlobHashes) > 0:
+		req.Type = types.BlobTxType
+	case args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil:
+		req.Type = types.DynamicFeeTxType
+	case args.AccessList != nil:
+		req.Type = types.AccessListTxType
+	default:
+		req.Type = types.LegacyTxType
+	}
+
+	switch req.Type {
+	case types.LegacyTxType, types.AccessListTxType:
+		if args.GasPrice == nil {
+			return nil, invalid("gasPrice is required for type %d", req.Type)
+		}
+		req.GasPrice = args.GasPrice.ToInt()
+	case types.DynamicFeeTxType, types.BlobTxType, types.SetCodeTxType:
+		if args.MaxFeePerGas == nil || args.MaxPriorityFeePerGas == nil {
+			return nil, invalid("maxFeePerGas and maxPriorityFeePerGas are required for type %d", req.Type)
+		}
+		req.GasFeeCap, req.GasTipCap = args.MaxFeePerGas.ToInt(), args.MaxPriorityFeePerGas.ToInt()
+	default:
+		return nil, invalid("unsupported transaction type %d", req.Type)
+	}
+	if req.Type == types.BlobTxType {
+		if args.MaxFeePerBlobGas == nil || len(args.BlobHashes) == 0 {
+			return nil, invalid("maxFeePerBlobGas and blobVersionedHashes are required for blob transactions")
+		}
+		req.BlobFeeCap = args.MaxFeePerBlobGas.ToInt()
+	}
+	return req, nil
+}
+
+// signTransactionResult is what geth returns for eth_signTransaction.
+type signTransactionResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// SignTransaction signs a fully specified transaction after checking it
+// against the policy. Blob transactions are signed without sidecar, the
+// sender attaches it.
+func (api *ethAPI) SignTransaction(ctx context.Context, args txArgs) (*signTransactionResult, error) {
+	api.spendMu.Lock()
+	defer api.spendMu.Unlock()
+	tx, err := api.signTransaction(args)
+	if err != nil {
+		return nil, api.record(ctx, "eth_signTransaction", args.From, nil, rejected(err))
+	}
+	// Audited before it is journaled: a transaction that counts against the
+	// daily limit is always in the audit log
+	hash := tx.Hash()
+	if err := api.record(ctx, "eth_signTransaction", args.From, &hash, nil); err != nil {
+		return nil, err
+	}
+	// Journaled without network: the caller sends it, but it counts towards
+	// the daily limit from now on
+	entry, err := journal.NewEntry("", tx, args.From)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.journal.Append(entry); err != nil {
+		return nil, fmt.Errorf("not signed: %w", err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &signTransactionResult{Raw: raw, Tx: tx}, nil
+}
+
+func (api *ethAPI) signTransaction(args txArgs) (*types.Transaction, error) {
+	key, err := api.key(args.From)
+	if err != nil {
+		return nil, err
+	}
+	req, err := args.request()
+	if err != nil {
+		return nil, err
+	}
+	if err := api.policy.Check(req); err != nil {
+		return nil, err
+	}
+	tx, err := req.Build()
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+	signer := types.LatestSignerForChainID(req.ChainID)
+	if err := api.journal.RecordBuilt(tx, args.From, signer); err != nil {
+		return nil, fmt.Errorf("not signed: %w", err)
+	}
+	return types.SignTx(tx, signer, key)
+}
+
+// SignTypedData_v4 signs EIP-712 typed data. It is served as
+// eth_signTypedData_v4; the typed data may be sent as an object or, like
+// wallets do, as a JSON string.
+func (api *ethAPI) SignTypedData_v4(ctx context.Context, from common.Address, input json.RawMessage) (hexutil.Bytes, error) {
+	sig, hash, err := api.signTypedData(from, input)
+	if err := api.record(ctx, "eth_signTypedData_v4", from, hash, rejected(err)); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (api *ethAPI) signTypedData(from common.Address, input json.RawMessage) (hexutil.Bytes, *common.Hash, error) {
+	key, err := api.key(from)
+	if err != nil {
+		return nil, nil, err
+	}
+	var encoded string
+	if json.Unmarshal(input, &encoded) == nil {
+		input = json.RawMessage(encoded)
+	}
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal(input, &typedData); err != nil {
+		return nil, nil, invalid("invalid typed data: %v", err)
+	}
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, nil, invalid("invalid typed data: %v", err)
+	}
+	hash := common.BytesToHash(digest)
+
+	var verifyingContract *common.Address
+	if vc := typedData.Domain.VerifyingContract; vc != "" {
+		if !common.IsHexAddress(vc) {
+			return nil, &hash, invalid("invalid verifyingContract %q", vc)
+		}
+		addr := common.HexToAddress(vc)
+		verifyingContract = &addr
+	}
+	if err := api.policy.CheckTypedData(from, verifyingContract); err != nil {
+		return nil, &hash, err
+	}
+	sig, err := sign(hash, key)
+	return sig, &hash, err
+}
+
+// authorizationArgs are the eth_signAuthorization parameters.
+type authorizationArgs struct {
+	From    common.Address  `json:"from"`
+	ChainID *hexutil.Big    `json:"chainId"`
+	Address common.Address  `json:"address"`
+	Nonce   *hexutil.Uint64 `json:"nonce"`
+}
+
+// SignAuthorization signs an EIP-7702 authorization delegating from to
+// address, for a transaction someone else sends. Chain ID 0 authorizations
+// are refused unless the policy allows them.
+func (api *ethAPI) SignAuthorization(ctx context.Context, args authorizationArgs) (*types.SetCodeAuthorization, error) {
+	auth, err := api.signAuthorization(args)
+	if err != nil {
+		return nil, api.record(ctx, "eth_signAuthorization", args.From, nil, rejected(err))
+	}
+	digest, err := authorizationHash(auth)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.record(ctx, "eth_signAuthorization", args.From, &digest, nil); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// authorizationHash is the digest an authorization signs:
+// keccak256(0x05 || rlp([chain_id, address, nonce])).
+func authorizationHash(auth *types.SetCodeAuthorization) (common.Hash, error) {
+	payload, err := rlp.EncodeToBytes([]any{auth.ChainID, auth.Address, auth.Nonce})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte{0x05}, payload), nil
+}
+
+func (api *ethAPI) signAuthorization(args authorizationArgs) (*types.SetCodeAuthorization, error) {
+	key, err := api.key(args.From)
+	if err != nil {
+		return nil, err
+	}
+	if args.ChainID == nil || args.Nonce == nil {
+		return nil, invalid("chainId and nonce are required")
+	}
+	chainID, overflow := uint256.FromBig(args.ChainID.ToInt())
+	if overflow {
+		return nil, invalid("chainId out of range")
+	}
+	auth := types.SetCodeAuthorization{ChainID: *chainID, Address: args.Address, Nonce: uint64(*args.Nonce)}
+	if err := api.policy.CheckAuthorization(args.From, auth); err != nil {
+		return nil, err
+	}
+	signed, err := types.SignSetCode(key, auth)
+	if err != nil {
+		return nil, err
+	}
+	return &signed, nil
+}
+
+// personalAPI is served in the personal namespace.
+type personalAPI struct{ *signer }
+
+// Sign signs data with the EIP-191 personal message prefix, v is 27/28 like
+// wallets return it. The password geth takes is accepted and ignored.
+func (api *personalAPI) Sign(ctx context.Context, data hexutil.Bytes, from common.Address, password *string) (hexutil.Bytes, error) {
+	hash := eip191.PersonalSignHash(data)
+	key, err := api.key(from)
+	if err == nil {
+		err = api.policy.CheckMessage(from)
+	}
+	var sig []byte
+	if err == nil {
+		sig, err = sign(hash, key)
+	}
+	if err := api.record(ctx, "personal_sign", from, &hash, rejected(err)); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// sign signs a digest with v as 27/28.
+func sign(hash common.Hash, key *ecdsa.PrivateKey) (hexutil.Bytes, error) {
+	sig, err := crypto.Sign(hash.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
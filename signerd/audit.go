@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Decisions recorded in the audit log
+const (
+	decisionSigned   = "signed"
+	decisionRejected = "rejected" // by the policy
+	decisionFailed   = "failed"   // bad request or unknown account
+)
+
+// auditEntry is one line of the audit log, written for every signing request.
+type auditEntry struct {
+	Time     time.Time       `json:"time"`
+	Remote   string          `json:"remote"`
+	Method   string          `json:"method"`
+	From     *common.Address `json:"from,omitempty"`
+	Decision string          `json:"decision"`
+	// Transaction hash, or the digest that was signed
+	Hash   *common.Hash `json:"hash,omitempty"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+// auditLog appends entries to a JSON lines file, synced before a signature
+// is handed out.
+type auditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func openAudit(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &auditLog{f: f}, nil
+}
+
+func (a *auditLog) write(e auditEntry) error {
+	e.Time = time.Now().UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return a.f.Sync()
+}
+
+func (a *auditLog) Close() error {
+	return a.f.Close()
+}
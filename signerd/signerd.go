@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"transactiontypes/account"
+	"transactiontypes/journal"
+	"transactiontypes/policy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const usage = `Usage:
+  signerd [flags]
+
+Serves JSON-RPC over HTTP for services that need signatures without holding
+keys:
+
+  eth_accounts
+  eth_signTransaction    fully specified transaction, returns {raw, tx}
+  eth_signTypedData_v4   [address, typedData]
+  eth_signAuthorization  {from, chainId, address, nonce}, EIP-7702
+  personal_sign          [data, address]
+
+Every request needs "Authorization: Bearer <token>", with the token read from
+-token-file or $SIGNERD_TOKEN. Transactions and authorizations are checked
+against the spending policy ($TX_POLICY), signed transactions are journaled
+and every request is written to the audit log.
+
+Messages are checked too, since a signed permit (EIP-2612, Permit2) spends
+tokens without any transaction: when the policy lists "contracts", typed data
+is only signed if its domain's verifyingContract is one of them, and
+"allowMessageSigning": false refuses eth_signTypedData_v4 and personal_sign
+altogether.
+
+A standalone EIP-7702 authorization hands the whole account to the contract
+it delegates to, so eth_signAuthorization fails closed: it is refused unless
+the policy lists "contracts" to delegate to, or sets
+"allowAuthorizationSigning": true.
+
+Flags:`
+
+// minTokenLength keeps guessable tokens out.
+const minTokenLength = 32
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8550", "listen address")
+	tokenFile := flag.String("token-file", "", "file holding the bearer token, instead of $SIGNERD_TOKEN")
+	accounts := flag.String("accounts", "1,2", "comma separated account numbers to serve")
+	auditPath := flag.String("audit", filepath.Join(filepath.Dir(journal.DefaultPath()), "signerd-audit.jsonl"), "audit log file")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	token, err := readToken(*tokenFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keys, err := loadKeys(*accounts)
+	if err != nil {
+		log.Fatal("Failed to load accounts:", err)
+	}
+	cfg, err := policy.LoadDefault()
+	if err != nil {
+		log.Fatal("Failed to load spending policy:", err)
+	}
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	// The policy counts daily spending from the journal signed transactions
+	// are written to, through the same handle
+	spending := policy.New(cfg, policy.JournalLedger{Journal: jrnl})
+	audit, err := openAudit(*auditPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer audit.Close()
+
+	handler, err := newHandler(&signer{keys: keys, policy: spending, journal: jrnl, audit: audit}, token)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	for addr := range keys {
+		fmt.Println("Serving account", addr.Hex())
+	}
+	fmt.Println("Listening on", *addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// newHandler serves the signing API behind bearer token authentication.
+func newHandler(s *signer, token string) (http.Handler, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &ethAPI{s}); err != nil {
+		return nil, err
+	}
+	if err := server.RegisterName("personal", &personalAPI{s}); err != nil {
+		return nil, err
+	}
+	return requireToken(token, server), nil
+}
+
+// requireToken rejects requests without the bearer token. The comparison
+// takes the same time whatever the token sent.
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			log.Printf("Unauthorized request from %s", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="signerd"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func readToken(path string) (string, error) {
+	token := os.Getenv("SIGNERD_TOKEN")
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		token = string(b)
+	}
+	token = strings.TrimSpace(token)
+	if len(token) < minTokenLength {
+		return "", fmt.Errorf("a bearer token of at least %d characters is required, set $SIGNERD_TOKEN or -token-file", minTokenLength)
+	}
+	return token, nil
+}
+
+func loadKeys(list string) (map[common.Address]*ecdsa.PrivateKey, error) {
+	keys := make(map[common.Address]*ecdsa.PrivateKey)
+	for _, field := range strings.Split(list, ",") {
+		accNum, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid account number %q", field)
+		}
+		addr, key, err := account.Load(accNum)
+		if err != nil {
+			return nil, err
+		}
+		keys[addr] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"transactiontypes/eip191"
+	"transactiontypes/journal"
+	"transactiontypes/policy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const testToken = "0123456789abcdef0123456789abcdef"
+
+var (
+	recipient = common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	token     = common.HexToAddress("0x00000000000000000000000000000000000000c1")
+)
+
+type testSigner struct {
+	url      string
+	from     common.Address
+	audit    string
+	auditLog *auditLog
+	journal  *journal.Journal
+}
+
+// slowLedger widens the window between reading today's spending and
+// journaling a new transaction.
+type slowLedger struct{ policy.Ledger }
+
+func (l slowLedger) Spent(from common.Address, since time.Time) (*big.Int, error) {
+	time.Sleep(20 * time.Millisecond)
+	return l.Ledger.Spent(from, since)
+}
+
+// newTestSigner serves one fresh key under the given policy.
+func newTestSigner(t *testing.T, rules string) *testSigner {
+	return newTestSignerWithLedger(t, rules, func(l policy.Ledger) policy.Ledger { return l })
+}
+
+func newTestSignerWithLedger(t *testing.T, rules string, ledger func(policy.Ledger) policy.Ledger) *testSigner {
+	t.Helper()
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(policyPath, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := policy.Load(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jrnl, err := journal.Open(filepath.Join(dir, "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditPath := filepath.Join(dir, "audit.jsonl")
+	audit, err := openAudit(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { audit.Close() })
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	handler, err := newHandler(&signer{
+		keys:    map[common.Address]*ecdsa.PrivateKey{from: key},
+		policy:  policy.New(cfg, ledger(policy.JournalLedger{Journal: jrnl})),
+		journal: jrnl,
+		audit:   audit,
+	}, testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &testSigner{url: srv.URL, from: from, audit: auditPath, auditLog: audit, journal: jrnl}
+}
+
+func (s *testSigner) dial(t *testing.T) *rpc.Client {
+	t.Helper()
+	client, err := rpc.DialOptions(context.Background(), s.url, rpc.WithHeader("Authorization", "Bearer "+testToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func (s *testSigner) auditEntries(t *testing.T) []auditEntry {
+	t.Helper()
+	f, err := os.Open(s.audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func transferArgs(from common.Address, nonce uint64, value *big.Int) map[string]any {
+	return map[string]any{
+		"from":                 from,
+		"to":                   recipient,
+		"chainId":              "0x1",
+		"nonce":                hexutil.Uint64(nonce),
+		"gas":                  "0x5208",
+		"maxFeePerGas":         hexutil.EncodeBig(big.NewInt(50 * 1e9)),
+		"maxPriorityFeePerGas": hexutil.EncodeBig(big.NewInt(1e9)),
+		"value":                hexutil.EncodeBig(value),
+	}
+}
+
+func ether(n float64) *big.Int {
+	v, _ := new(big.Float).Mul(big.NewFloat(n), big.NewFloat(1e18)).Int(nil)
+	return v
+}
+
+// rpcError returns the code and violated rules of a failed call.
+func rpcError(t *testing.T, err error) (int, []string) {
+	t.Helper()
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("err = %v, want an RPC error", err)
+	}
+	var rules []string
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) && dataErr.ErrorData() != nil {
+		encoded, _ := json.Marshal(dataErr.ErrorData())
+		var violations []policy.Violation
+		if err := json.Unmarshal(encoded, &violations); err != nil {
+			t.Fatalf("error data %s: %v", encoded, err)
+		}
+		for _, v := range violations {
+			rules = append(rules, v.Rule)
+		}
+	}
+	return rpcErr.ErrorCode(), rules
+}
+
+func TestRequireToken(t *testing.T) {
+	s := newTestSigner(t, `{}`)
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_accounts","params":[]}`
+	for name, header := range map[string]string{
+		"missing":    "",
+		"wrong":      "Bearer " + strings.Repeat("x", len(testToken)),
+		"not bearer": testToken,
+		"prefix":     "Bearer " + testToken[:16],
+		"valid":      "Bearer " + testToken,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, s.url, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			want := http.StatusUnauthorized
+			if name == "valid" {
+				want = http.StatusOK
+			}
+			if resp.StatusCode != want {
+				t.Fatalf("status %d, want %d", resp.StatusCode, want)
+			}
+		})
+	}
+}
+
+func TestSignTransaction(t *testing.T) {
+	s := newTestSigner(t, `{"default": {"dailyValueLimit": "1 ether", "maxFeePerGas": "100 gwei"}}`)
+	client := s.dial(t)
+
+	var res signTransactionResult
+	if err := client.Call(&res, "eth_signTransaction", transferArgs(s.from, 0, ether(0.6))); err != nil {
+		t.Fatal(err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(res.Raw); err != nil {
+		t.Fatal(err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), tx)
+	if err != nil || sender != s.from || tx.Type() != types.DynamicFeeTxType || res.Tx.Hash() != tx.Hash() {
+		t.Fatalf("signed type %d tx from %s (%v)", tx.Type(), sender.Hex(), err)
+	}
+
+	// 0.6 ether is journaled, another 0.6 breaks the daily limit
+	args := transferArgs(s.from, 1, ether(0.6))
+	args["maxFeePerGas"] = hexutil.EncodeBig(big.NewInt(150 * 1e9))
+	code, rules := rpcError(t, client.Call(&res, "eth_signTransaction", args))
+	if code != codeRejected || !slices.Equal(rules, []string{"dailyValueLimit", "maxFeePerGas"}) {
+		t.Fatalf("code %d, rules %v", code, rules)
+	}
+
+	code, _ = rpcError(t, client.Call(&res, "eth_signTransaction", transferArgs(recipient, 0, ether(0.1))))
+	if code != codeUnauthorized {
+		t.Fatalf("unknown from: code %d, want %d", code, codeUnauthorized)
+	}
+
+	for _, chainID := range []any{"0x0", nil} {
+		args := transferArgs(s.from, 1, ether(0.1))
+		args["chainId"] = chainID
+		code, _ = rpcError(t, client.Call(&res, "eth_signTransaction", args))
+		if code != codeInvalid {
+			t.Fatalf("chainId %v: code %d, want %d", chainID, code, codeInvalid)
+		}
+	}
+
+	want := []string{decisionSigned, decisionRejected, decisionFailed, decisionFailed, decisionFailed}
+	var got []string
+	for _, entry := range s.auditEntries(t) {
+		if entry.Method != "eth_signTransaction" {
+			t.Errorf("audited method %s", entry.Method)
+		}
+		got = append(got, entry.Decision)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("audited %v, want %v", got, want)
+	}
+}
+
+// A transaction the audit log couldn't record is not handed out, and must not
+// count against the daily limit either.
+func TestSignTransactionAuditFailure(t *testing.T) {
+	s := newTestSigner(t, `{"default": {"dailyValueLimit": "1 ether"}}`)
+	client := s.dial(t)
+	s.auditLog.Close()
+
+	var res signTransactionResult
+	if err := client.Call(&res, "eth_signTransaction", transferArgs(s.from, 0, ether(0.6))); err == nil {
+		t.Fatal("signed without an audit log")
+	}
+	spent, err := policy.JournalLedger{Journal: s.journal}.Spent(s.from, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent.Sign() != 0 {
+		t.Fatalf("unaudited transaction counts %s wei against the daily limit", spent)
+	}
+}
+
+// Parallel requests must not all pass the daily limit on the same total.
+func TestDailyLimitUnderConcurrency(t *testing.T) {
+	s := newTestSignerWithLedger(t, `{"default": {"dailyValueLimit": "1 ether"}}`, func(l policy.Ledger) policy.Ledger {
+		return slowLedger{l}
+	})
+	client := s.dial(t)
+
+	const requests = 10
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		signed int
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(nonce uint64) {
+			defer wg.Done()
+			var res signTransactionResult
+			if err := client.Call(&res, "eth_signTransaction", transferArgs(s.from, nonce, ether(0.3))); err == nil {
+				mu.Lock()
+				signed++
+				mu.Unlock()
+			}
+		}(uint64(i))
+	}
+	wg.Wait()
+	if signed != 3 {
+		t.Fatalf("signed %d transfers of 0.3 ether under a 1 ether limit, want 3", signed)
+	}
+	if entries := s.auditEntries(t); len(entries) != requests {
+		t.Fatalf("%d audit lines for %d requests", len(entries), requests)
+	}
+}
+
+func TestSignAuthorization(t *testing.T) {
+	s := newTestSigner(t, `{"default": {"contracts": ["`+token.Hex()+`"]}}`)
+	client := s.dial(t)
+
+	var auth types.SetCodeAuthorization
+	args := map[string]any{"from": s.from, "chainId": "0x1", "address": token, "nonce": "0x3"}
+	if err := client.Call(&auth, "eth_signAuthorization", args); err != nil {
+		t.Fatal(err)
+	}
+	if authority, err := auth.Authority(); err != nil || authority != s.from {
+		t.Fatalf("authority %s (%v), want %s", authority.Hex(), err, s.from.Hex())
+	}
+
+	args["chainId"] = "0x0"
+	code, rules := rpcError(t, client.Call(&auth, "eth_signAuthorization", args))
+	if code != codeRejected || !slices.Equal(rules, []string{"allowAnyChainAuthorizations"}) {
+		t.Fatalf("chain ID 0: code %d, rules %v", code, rules)
+	}
+
+	args["from"] = recipient
+	args["chainId"] = "0x1"
+	code, _ = rpcError(t, client.Call(&auth, "eth_signAuthorization", args))
+	if code != codeUnauthorized {
+		t.Fatalf("unknown from: code %d, want %d", code, codeUnauthorized)
+	}
+
+	entries := s.auditEntries(t)
+	if len(entries) != 3 || entries[0].Decision != decisionSigned || entries[1].Decision != decisionRejected || entries[2].Decision != decisionFailed {
+		t.Fatalf("audit %+v", entries)
+	}
+}
+
+// Without a contracts allowlist a bearer token must not be enough to get
+// the account delegated.
+func TestSignAuthorizationRefusedByDefault(t *testing.T) {
+	s := newTestSigner(t, `{}`)
+	client := s.dial(t)
+
+	var auth types.SetCodeAuthorization
+	args := map[string]any{"from": s.from, "chainId": "0x1", "address": token, "nonce": "0x3"}
+	code, rules := rpcError(t, client.Call(&auth, "eth_signAuthorization", args))
+	if code != codeRejected || !slices.Equal(rules, []string{"allowAuthorizationSigning"}) {
+		t.Fatalf("code %d, rules %v", code, rules)
+	}
+	if entries := s.auditEntries(t); len(entries) != 1 || entries[0].Decision != decisionRejected {
+		t.Fatalf("audit %+v", entries)
+	}
+}
+
+func typedData(verifyingContract string) string {
+	domain := `{"name": "Token", "chainId": "1"}`
+	domainType := `[{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}]`
+	if verifyingContract != "" {
+		domain = `{"name": "Token", "chainId": "1", "verifyingContract": "` + verifyingContract + `"}`
+		domainType = `[{"name": "name", "type": "string"}, {"name": "chainId", "type": "uint256"}, {"name": "verifyingContract", "type": "address"}]`
+	}
+	return `{
+		"types": {
+			"EIP712Domain": ` + domainType + `,
+			"Permit": [{"name": "spender", "type": "address"}, {"name": "value", "type": "uint256"}]
+		},
+		"primaryType": "Permit",
+		"domain": ` + domain + `,
+		"message": {"spender": "` + recipient.Hex() + `", "value": "1000"}
+	}`
+}
+
+func TestSignTypedData(t *testing.T) {
+	s := newTestSigner(t, `{"default": {"contracts": ["`+token.Hex()+`"]}}`)
+	client := s.dial(t)
+
+	// Wallets send the typed data as a JSON string, others as an object
+	var asString, asObject hexutil.Bytes
+	if err := client.Call(&asString, "eth_signTypedData_v4", s.from, typedData(token.Hex())); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&asObject, "eth_signTypedData_v4", s.from, json.RawMessage(typedData(token.Hex()))); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(asString, asObject) || asString[64] < 27 {
+		t.Fatalf("signatures %x and %x", asString, asObject)
+	}
+
+	var sig hexutil.Bytes
+	for name, data := range map[string]string{
+		"contract not allowed":  typedData(recipient.Hex()),
+		"no verifying contract": typedData(""),
+	} {
+		code, rules := rpcError(t, client.Call(&sig, "eth_signTypedData_v4", s.from, data))
+		if code != codeRejected || !slices.Equal(rules, []string{"contracts"}) {
+			t.Errorf("%s: code %d, rules %v", name, code, rules)
+		}
+	}
+	if entries := s.auditEntries(t); len(entries) != 4 {
+		t.Fatalf("%d audit lines for 4 requests", len(entries))
+	}
+}
+
+func TestPersonalSign(t *testing.T) {
+	s := newTestSigner(t, `{}`)
+	client := s.dial(t)
+
+	message := []byte("hello")
+	var sig hexutil.Bytes
+	if err := client.Call(&sig, "personal_sign", hexutil.Bytes(message), s.from); err != nil {
+		t.Fatal(err)
+	}
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(eip191.PersonalSignHash(message).Bytes(), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != s.from {
+		t.Fatalf("signature does not recover to %s (%v)", s.from.Hex(), err)
+	}
+}
+
+func TestMessageSigningDisabled(t *testing.T) {
+	s := newTestSigner(t, `{"default": {"allowMessageSigning": false}}`)
+	client := s.dial(t)
+
+	var sig hexutil.Bytes
+	code, rules := rpcError(t, client.Call(&sig, "personal_sign", hexutil.Bytes("hello"), s.from))
+	if code != codeRejected || !slices.Equal(rules, []string{"allowMessageSigning"}) {
+		t.Fatalf("personal_sign: code %d, rules %v", code, rules)
+	}
+	code, rules = rpcError(t, client.Call(&sig, "eth_signTypedData_v4", s.from, typedData(token.Hex())))
+	if code != codeRejected || !slices.Equal(rules, []string{"allowMessageSigning"}) {
+		t.Fatalf("eth_signTypedData_v4: code %d, rules %v", code, rules)
+	}
+	// Transactions are not affected
+	var res signTransactionResult
+	if err := client.Call(&res, "eth_signTransaction", transferArgs(s.from, 0, ether(0.1))); err != nil {
+		t.Fatal(err)
+	}
+	if entries := s.auditEntries(t); len(entries) != 3 || entries[0].Decision != decisionRejected {
+		t.Fatalf("audit %+v", entries)
+	}
+}
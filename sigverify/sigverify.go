@@ -0,0 +1,222 @@
+package sigverify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const erc1271ABI = `[{"inputs":[{"internalType":"bytes32","name":"hash","type":"bytes32"},{"internalType":"bytes","name":"signature","type":"bytes"}],"name":"isValidSignature","outputs":[{"internalType":"bytes4","name":"magicValue","type":"bytes4"}],"stateMutability":"view","type":"function"}]`
+
+var (
+	// ERC-1271 magic value: bytes4(keccak256("isValidSignature(bytes32,bytes)"))
+	erc1271MagicValue = [4]byte{0x16, 0x26, 0xba, 0x7e}
+
+	// ERC-6492 wrapped signatures end with this 32 byte suffix
+	erc6492MagicSuffix = common.FromHex("0x6492649264926492649264926492649264926492649264926492649264926492")
+
+	erc1271 = mustParseABI(erc1271ABI)
+
+	// abi.encode(address factory, bytes factoryCalldata, bytes signature)
+	erc6492Wrapper = abi.Arguments{
+		{Type: mustNewType("address")},
+		{Type: mustNewType("bytes")},
+		{Type: mustNewType("bytes")},
+	}
+)
+
+// Verifier checks signatures for both EOAs and smart accounts.
+type Verifier struct {
+	client *ethclient.Client
+}
+
+// NewVerifier returns a Verifier that uses client for contract calls.
+func NewVerifier(client *ethclient.Client) *Verifier {
+	return &Verifier{client: client}
+}
+
+// VerifySignature reports whether sig is a valid signature of hash by signer.
+// It tries ECDSA recovery first, then ERC-1271 isValidSignature on the signer's
+// code (contract wallets and EIP-7702 delegated EOAs), and unwraps ERC-6492
+// signatures for accounts that have not been deployed yet.
+func (v *Verifier) VerifySignature(ctx context.Context, signer common.Address, hash common.Hash, sig []byte) (bool, error) {
+	if IsERC6492(sig) {
+		return v.verifyERC6492(ctx, signer, hash, sig)
+	}
+
+	if len(sig) == crypto.SignatureLength {
+		recovered, err := RecoverAddress(hash, sig)
+		if err == nil && recovered == signer {
+			return true, nil
+		}
+	}
+
+	code, err := v.client.CodeAt(ctx, signer, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch code of %s: %w", signer.Hex(), err)
+	}
+	if len(code) == 0 {
+		return false, nil
+	}
+	return v.isValidERC1271(ctx, signer, hash, sig)
+}
+
+// verifyERC6492 handles a counterfactual signature. If the account is already
+// deployed the inner signature is checked directly, otherwise the factory call
+// and the ERC-1271 check are simulated together in a single block.
+func (v *Verifier) verifyERC6492(ctx context.Context, signer common.Address, hash common.Hash, sig []byte) (bool, error) {
+	factory, factoryCalldata, innerSig, err := UnwrapERC6492(sig)
+	if err != nil {
+		return false, err
+	}
+
+	code, err := v.client.CodeAt(ctx, signer, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch code of %s: %w", signer.Hex(), err)
+	}
+	if len(code) > 0 {
+		valid, err := v.isValidERC1271(ctx, signer, hash, innerSig)
+		if err != nil || valid {
+			return valid, err
+		}
+		// The account may still need the factory call to be brought up to date
+	}
+
+	checkCalldata, err := erc1271.Pack("isValidSignature", hash, innerSig)
+	if err != nil {
+		return false, err
+	}
+	results, err := v.simulate(ctx, []simCall{
+		{To: factory, Input: factoryCalldata},
+		{To: signer, Input: checkCalldata},
+	})
+	if err != nil {
+		return false, err
+	}
+	deploy, check := results[0], results[1]
+	if deploy.Status != 1 {
+		return false, fmt.Errorf("ERC-6492 factory call to %s failed", factory.Hex())
+	}
+	return check.Status == 1 && hasMagicValue(check.ReturnData), nil
+}
+
+// isValidERC1271 calls isValidSignature(bytes32,bytes) on the signer.
+// A revert is treated as an invalid signature rather than an error.
+func (v *Verifier) isValidERC1271(ctx context.Context, signer common.Address, hash common.Hash, sig []byte) (bool, error) {
+	calldata, err := erc1271.Pack("isValidSignature", hash, sig)
+	if err != nil {
+		return false, err
+	}
+	res, err := v.client.CallContract(ctx, ethereum.CallMsg{To: &signer, Data: calldata}, nil)
+	if err != nil {
+		var dataErr rpc.DataError
+		if errors.As(err, &dataErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("isValidSignature call failed: %w", err)
+	}
+	return hasMagicValue(res), nil
+}
+
+type simCall struct {
+	To    common.Address `json:"to"`
+	Input hexutil.Bytes  `json:"input"`
+}
+
+type simCallResult struct {
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Status     hexutil.Uint64 `json:"status"`
+}
+
+// simulate executes calls sequentially on top of the latest block with
+// eth_simulateV1, so state written by one call is visible to the next.
+func (v *Verifier) simulate(ctx context.Context, calls []simCall) ([]simCallResult, error) {
+	opts := map[string]interface{}{
+		"blockStateCalls": []map[string]interface{}{{"calls": calls}},
+	}
+	var blocks []struct {
+		Calls []simCallResult `json:"calls"`
+	}
+	if err := v.client.Client().CallContext(ctx, &blocks, "eth_simulateV1", opts, "latest"); err != nil {
+		return nil, fmt.Errorf("eth_simulateV1 failed: %w", err)
+	}
+	if len(blocks) != 1 || len(blocks[0].Calls) != len(calls) {
+		return nil, errors.New("unexpected eth_simulateV1 response")
+	}
+	return blocks[0].Calls, nil
+}
+
+// IsERC6492 reports whether sig carries the ERC-6492 magic suffix.
+func IsERC6492(sig []byte) bool {
+	return len(sig) >= len(erc6492MagicSuffix) && bytes.HasSuffix(sig, erc6492MagicSuffix)
+}
+
+// UnwrapERC6492 splits an ERC-6492 signature into the factory address, the
+// factory calldata that deploys the account, and the inner signature.
+func UnwrapERC6492(sig []byte) (common.Address, []byte, []byte, error) {
+	if !IsERC6492(sig) {
+		return common.Address{}, nil, nil, errors.New("not an ERC-6492 signature")
+	}
+	values, err := erc6492Wrapper.Unpack(sig[:len(sig)-len(erc6492MagicSuffix)])
+	if err != nil {
+		return common.Address{}, nil, nil, fmt.Errorf("invalid ERC-6492 wrapper: %w", err)
+	}
+	return values[0].(common.Address), values[1].([]byte), values[2].([]byte), nil
+}
+
+// WrapERC6492 builds an ERC-6492 signature for a not yet deployed account.
+func WrapERC6492(factory common.Address, factoryCalldata, sig []byte) ([]byte, error) {
+	packed, err := erc6492Wrapper.Pack(factory, factoryCalldata, sig)
+	if err != nil {
+		return nil, err
+	}
+	return append(packed, erc6492MagicSuffix...), nil
+}
+
+// RecoverAddress recovers the signer of hash, accepting v as 0/1 or 27/28.
+func RecoverAddress(hash common.Hash, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("invalid signature length %d, want %d", len(sig), crypto.SignatureLength)
+	}
+	normalized := make([]byte, crypto.SignatureLength)
+	copy(normalized, sig)
+	if normalized[64] >= 27 {
+		normalized[64] -= 27
+	}
+	pubKey, err := crypto.SigToPub(hash.Bytes(), normalized)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+func hasMagicValue(res []byte) bool {
+	// bytes4 return values are left aligned in a 32 byte word
+	return len(res) >= 4 && bytes.Equal(res[:4], erc1271MagicValue[:])
+}
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func mustNewType(t string) abi.Type {
+	typ, err := abi.NewType(t, "", nil)
+	if err != nil {
+		panic(err)
+	}
+	return typ
+}
@@ -0,0 +1,169 @@
+package sigverify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/program"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// walletCode returns the runtime of a minimal ERC-1271 wallet: it ecrecovers
+// the 65 byte signature passed to isValidSignature(bytes32,bytes) and returns
+// the magic value if it recovers to owner, zero otherwise.
+func walletCode(owner common.Address) []byte {
+	magic := make([]byte, 32)
+	copy(magic, erc1271MagicValue[:])
+	return program.New().
+		// ecrecover input: hash, v, r, s. The signature starts at calldata 100.
+		Push(4).Op(vm.CALLDATALOAD).Push(0).Op(vm.MSTORE).
+		Push(164).Op(vm.CALLDATALOAD).Push(248).Op(vm.SHR).Push(32).Op(vm.MSTORE).
+		Push(100).Op(vm.CALLDATALOAD).Push(64).Op(vm.MSTORE).
+		Push(132).Op(vm.CALLDATALOAD).Push(96).Op(vm.MSTORE).
+		StaticCall(nil, 1, 0, 128, 0, 32).Op(vm.POP).
+		// magic * (recovered == owner)
+		Push(0).Op(vm.MLOAD).Push(owner).Op(vm.EQ).
+		Push(magic).Op(vm.MUL).
+		Push(0).Op(vm.MSTORE).
+		Return(0, 32).
+		Bytes()
+}
+
+// factoryCode returns the runtime of a factory that deploys initcode with
+// CREATE2 and salt zero on every call.
+func factoryCode(initcode []byte) []byte {
+	return program.New().Create2(initcode, 0).Op(vm.POP, vm.STOP).Bytes()
+}
+
+// newClient starts a simulated chain with alloc and connects to it over IPC,
+// the Verifier needs a full ethclient for eth_simulateV1.
+func newClient(t *testing.T, alloc types.GenesisAlloc) *ethclient.Client {
+	t.Helper()
+	ipc := filepath.Join(t.TempDir(), "sim.ipc")
+	sim := simulated.NewBackend(alloc, func(nodeConf *node.Config, _ *ethconfig.Config) {
+		nodeConf.IPCPath = ipc
+	})
+	t.Cleanup(func() { sim.Close() })
+	client, err := ethclient.Dial(ipc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, hash common.Hash) []byte {
+	t.Helper()
+	sig, err := crypto.Sign(hash.Bytes(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestVerifySignature(t *testing.T) {
+	owner, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	ownerAddr := crypto.PubkeyToAddress(owner.PublicKey)
+
+	var (
+		accepting = common.HexToAddress("0x1271000000000000000000000000000000000001")
+		rejecting = common.HexToAddress("0x1271000000000000000000000000000000000002")
+		factory   = common.HexToAddress("0x6492000000000000000000000000000000000001")
+		initcode  = program.New().ReturnViaCodeCopy(walletCode(ownerAddr)).Bytes()
+		// The account the factory will deploy, not on chain yet
+		counterfactual = crypto.CreateAddress2(factory, common.Hash{}, crypto.Keccak256(initcode))
+	)
+	client := newClient(t, types.GenesisAlloc{
+		ownerAddr: {Balance: big.NewInt(1e18)},
+		accepting: {Code: walletCode(ownerAddr)},
+		rejecting: {Code: walletCode(crypto.PubkeyToAddress(other.PublicKey))},
+		factory:   {Code: factoryCode(initcode)},
+	})
+
+	hash := crypto.Keccak256Hash([]byte("hello"))
+	sig := sign(t, owner, hash)
+	sig27 := append([]byte(nil), sig...)
+	sig27[64] += 27
+	wrapped, err := WrapERC6492(factory, common.FromHex("0x12345678"), sig27)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappedOther, err := WrapERC6492(factory, common.FromHex("0x12345678"), sign(t, other, hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		signer common.Address
+		sig    []byte
+		want   bool
+	}{
+		{"eoa v 0/1", ownerAddr, sig, true},
+		{"eoa v 27/28", ownerAddr, sig27, true},
+		{"eoa wrong signer", crypto.PubkeyToAddress(other.PublicKey), sig, false},
+		{"erc1271 accepts", accepting, sig27, true},
+		{"erc1271 rejects", rejecting, sig27, false},
+		{"erc1271 short signature", accepting, sig27[:64], false},
+		{"erc6492 counterfactual", counterfactual, wrapped, true},
+		{"erc6492 counterfactual wrong key", counterfactual, wrappedOther, false},
+		{"counterfactual without wrapper", counterfactual, sig27, false},
+	}
+	verifier := NewVerifier(client)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifier.VerifySignature(context.Background(), tt.signer, hash, tt.sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifySignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformedERC6492(t *testing.T) {
+	client := newClient(t, nil)
+	verifier := NewVerifier(client)
+
+	sig := append(common.FromHex("0xdeadbeef"), erc6492MagicSuffix...)
+	_, err := verifier.VerifySignature(context.Background(), common.Address{1}, common.Hash{}, sig)
+	if err == nil || !strings.Contains(err.Error(), "invalid ERC-6492 wrapper") {
+		t.Fatalf("err = %v, want invalid wrapper", err)
+	}
+}
+
+func TestERC6492RoundTrip(t *testing.T) {
+	factory := common.HexToAddress("0x6492000000000000000000000000000000000001")
+	calldata, inner := []byte{1, 2, 3}, make([]byte, 65)
+	sig, err := WrapERC6492(factory, calldata, inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsERC6492(sig) {
+		t.Fatal("wrapped signature not detected")
+	}
+	gotFactory, gotCalldata, gotInner, err := UnwrapERC6492(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotFactory != factory || string(gotCalldata) != string(calldata) || string(gotInner) != string(inner) {
+		t.Fatalf("unwrap = %x %x %x", gotFactory, gotCalldata, gotInner)
+	}
+	if IsERC6492(inner) {
+		t.Fatal("plain signature detected as ERC-6492")
+	}
+}
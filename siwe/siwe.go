@@ -0,0 +1,288 @@
+package siwe
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"transactiontypes/eip191"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	headerSuffix = " wants you to sign in with your Ethereum account:"
+
+	uriTag        = "URI: "
+	versionTag    = "Version: "
+	chainIDTag    = "Chain ID: "
+	nonceTag      = "Nonce: "
+	issuedAtTag   = "Issued At: "
+	expirationTag = "Expiration Time: "
+	notBeforeTag  = "Not Before: "
+	requestIDTag  = "Request ID: "
+	resourcesTag  = "Resources:"
+
+	nonceAlphabet  = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	nonceLength    = 17
+	minNonceLength = 8
+)
+
+// Message is an EIP-4361 Sign-In with Ethereum message.
+type Message struct {
+	Scheme         string // optional, e.g. "https"
+	Domain         string
+	Address        common.Address
+	Statement      string // optional, must not contain newlines
+	URI            string
+	Version        string // always "1" for now
+	ChainID        uint64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// String returns the message in its canonical EIP-4361 text form, which is
+// what gets signed with personal_sign.
+func (m *Message) String() string {
+	var b strings.Builder
+
+	if m.Scheme != "" {
+		b.WriteString(m.Scheme + "://")
+	}
+	b.WriteString(m.Domain + headerSuffix + "\n")
+	b.WriteString(m.Address.Hex() + "\n")
+	b.WriteString("\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString(uriTag + m.URI + "\n")
+	b.WriteString(versionTag + m.Version + "\n")
+	b.WriteString(chainIDTag + strconv.FormatUint(m.ChainID, 10) + "\n")
+	b.WriteString(nonceTag + m.Nonce + "\n")
+	b.WriteString(issuedAtTag + m.IssuedAt.UTC().Format(time.RFC3339))
+	if m.ExpirationTime != nil {
+		b.WriteString("\n" + expirationTag + m.ExpirationTime.UTC().Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\n" + notBeforeTag + m.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\n" + requestIDTag + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\n" + resourcesTag)
+		for _, r := range m.Resources {
+			b.WriteString("\n- " + r)
+		}
+	}
+	return b.String()
+}
+
+// Hash returns the EIP-191 personal_sign hash of the message.
+func (m *Message) Hash() common.Hash {
+	return eip191.PersonalSignHash([]byte(m.String()))
+}
+
+// Validate checks the fields required by EIP-4361.
+func (m *Message) Validate() error {
+	switch {
+	case m.Domain == "":
+		return errors.New("siwe: missing domain")
+	case strings.Contains(m.Statement, "\n"):
+		return errors.New("siwe: statement must not contain newlines")
+	case m.URI == "":
+		return errors.New("siwe: missing URI")
+	case m.Version != "1":
+		return fmt.Errorf("siwe: unsupported version %q", m.Version)
+	case len(m.Nonce) < minNonceLength || !isAlphanumeric(m.Nonce):
+		return errors.New("siwe: nonce must be at least 8 alphanumeric characters")
+	case m.IssuedAt.IsZero():
+		return errors.New("siwe: missing issued-at time")
+	}
+	return nil
+}
+
+// Sign validates the message and signs it with priv using personal_sign.
+// The returned signature uses v = 27/28 like wallets do.
+func Sign(m *Message, priv *ecdsa.PrivateKey) ([]byte, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if crypto.PubkeyToAddress(priv.PublicKey) != m.Address {
+		return nil, errors.New("siwe: signing key does not match message address")
+	}
+	hash := m.Hash()
+	sig, err := crypto.Sign(hash.Bytes(), priv)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// GenerateNonce returns a random alphanumeric nonce. Each character is drawn
+// uniformly from the alphabet, reducing random bytes modulo its length would
+// favour the first characters.
+func GenerateNonce() (string, error) {
+	buf := make([]byte, nonceLength)
+	size := big.NewInt(int64(len(nonceAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = nonceAlphabet[n.Int64()]
+	}
+	return string(buf), nil
+}
+
+// ParseMessage parses the EIP-4361 text form of a message.
+func ParseMessage(s string) (*Message, error) {
+	lines := strings.Split(s, "\n")
+	m := new(Message)
+
+	next := func() (string, bool) {
+		if len(lines) == 0 {
+			return "", false
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, true
+	}
+
+	// Header and address
+	header, _ := next()
+	if !strings.HasSuffix(header, headerSuffix) {
+		return nil, errors.New("siwe: invalid message header")
+	}
+	m.Domain = strings.TrimSuffix(header, headerSuffix)
+	if scheme, domain, ok := strings.Cut(m.Domain, "://"); ok {
+		m.Scheme, m.Domain = scheme, domain
+	}
+	address, _ := next()
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("siwe: invalid address %q", address)
+	}
+	m.Address = common.HexToAddress(address)
+	if m.Address.Hex() != address {
+		return nil, fmt.Errorf("siwe: address %q is not EIP-55 checksummed", address)
+	}
+
+	// Empty line, optional statement, empty line
+	if line, ok := next(); !ok || line != "" {
+		return nil, errors.New("siwe: expected empty line after address")
+	}
+	line, _ := next()
+	if line != "" {
+		m.Statement = line
+		if line, ok := next(); !ok || line != "" {
+			return nil, errors.New("siwe: expected empty line after statement")
+		}
+	}
+
+	// Required fields
+	required := []struct {
+		tag   string
+		value *string
+	}{
+		{uriTag, &m.URI},
+		{versionTag, &m.Version},
+	}
+	for _, field := range required {
+		line, _ := next()
+		value, ok := strings.CutPrefix(line, field.tag)
+		if !ok {
+			return nil, fmt.Errorf("siwe: expected %q", strings.TrimSpace(field.tag))
+		}
+		*field.value = value
+	}
+
+	line, _ = next()
+	chainID, ok := strings.CutPrefix(line, chainIDTag)
+	if !ok {
+		return nil, errors.New("siwe: expected \"Chain ID:\"")
+	}
+	id, err := strconv.ParseUint(chainID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("siwe: invalid chain ID: %w", err)
+	}
+	m.ChainID = id
+
+	line, _ = next()
+	if m.Nonce, ok = strings.CutPrefix(line, nonceTag); !ok {
+		return nil, errors.New("siwe: expected \"Nonce:\"")
+	}
+
+	line, _ = next()
+	issuedAt, ok := strings.CutPrefix(line, issuedAtTag)
+	if !ok {
+		return nil, errors.New("siwe: expected \"Issued At:\"")
+	}
+	if m.IssuedAt, err = time.Parse(time.RFC3339, issuedAt); err != nil {
+		return nil, fmt.Errorf("siwe: invalid issued-at time: %w", err)
+	}
+
+	// Optional fields, in the order defined by the spec
+	line, ok = next()
+	if value, found := strings.CutPrefix(line, expirationTag); ok && found {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("siwe: invalid expiration time: %w", err)
+		}
+		m.ExpirationTime = &t
+		line, ok = next()
+	}
+	if value, found := strings.CutPrefix(line, notBeforeTag); ok && found {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("siwe: invalid not-before time: %w", err)
+		}
+		m.NotBefore = &t
+		line, ok = next()
+	}
+	if value, found := strings.CutPrefix(line, requestIDTag); ok && found {
+		m.RequestID = value
+		line, ok = next()
+	}
+	if ok && line == resourcesTag {
+		for {
+			line, ok = next()
+			if !ok {
+				break
+			}
+			resource, found := strings.CutPrefix(line, "- ")
+			if !found {
+				return nil, fmt.Errorf("siwe: invalid resource line %q", line)
+			}
+			m.Resources = append(m.Resources, resource)
+		}
+	}
+	if ok {
+		return nil, fmt.Errorf("siwe: unexpected line %q", line)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func isAlphanumeric(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune(nonceAlphabet, c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package siwe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"transactiontypes/sigverify"
+)
+
+var (
+	ErrDomainMismatch   = errors.New("siwe: domain mismatch")
+	ErrChainIDMismatch  = errors.New("siwe: chain ID mismatch")
+	ErrExpired          = errors.New("siwe: message expired")
+	ErrNotYetValid      = errors.New("siwe: message not yet valid")
+	ErrInvalidNonce     = errors.New("siwe: nonce unknown or already used")
+	ErrInvalidSignature = errors.New("siwe: invalid signature")
+)
+
+// NonceStore issues nonces and makes sure each one is accepted only once,
+// which is what protects a login against replay.
+type NonceStore interface {
+	// Issue returns a fresh nonce to be embedded in a message.
+	Issue() (string, error)
+	// Consume marks nonce as used. It returns ErrInvalidNonce if the nonce was
+	// never issued, has expired or was already consumed.
+	Consume(nonce string) error
+}
+
+// MemoryNonceStore is an in-process NonceStore. Nonces not consumed within
+// the TTL are dropped.
+type MemoryNonceStore struct {
+	ttl    time.Duration
+	mu     sync.Mutex
+	issued map[string]time.Time
+}
+
+// NewMemoryNonceStore returns a MemoryNonceStore whose nonces live for ttl.
+func NewMemoryNonceStore(ttl time.Duration) *MemoryNonceStore {
+	return &MemoryNonceStore{ttl: ttl, issued: make(map[string]time.Time)}
+}
+
+// Issue returns a fresh nonce that can be consumed once within the TTL.
+func (s *MemoryNonceStore) Issue() (string, error) {
+	nonce, err := GenerateNonce()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.issued[nonce] = time.Now().Add(s.ttl)
+	return nonce, nil
+}
+
+// Consume accepts nonce once if it was issued by s and has not expired.
+func (s *MemoryNonceStore) Consume(nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	if _, ok := s.issued[nonce]; !ok {
+		return ErrInvalidNonce
+	}
+	delete(s.issued, nonce)
+	return nil
+}
+
+// prune drops expired nonces. Callers must hold s.mu.
+func (s *MemoryNonceStore) prune() {
+	now := time.Now()
+	for nonce, expiry := range s.issued {
+		if now.After(expiry) {
+			delete(s.issued, nonce)
+		}
+	}
+}
+
+// VerifyOptions configures Verify.
+type VerifyOptions struct {
+	// Domain the message must have been created for.
+	Domain string
+	// ChainID, if non-zero, the message must be bound to.
+	ChainID uint64
+	// Nonces is consulted last, so a nonce is only burned by messages that
+	// passed every other check.
+	Nonces NonceStore
+	// Verifier, if set, is used to accept ERC-1271/ERC-6492 signatures from
+	// smart accounts. Without it only EOA signatures are accepted.
+	Verifier *sigverify.Verifier
+	// Now overrides the current time, mainly for tests.
+	Now func() time.Time
+}
+
+// Verify checks that sig is a valid signature of m and that the message is
+// meant for this domain, currently valid and has not been used before.
+func Verify(ctx context.Context, m *Message, sig []byte, opts VerifyOptions) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if m.Domain != opts.Domain {
+		return fmt.Errorf("%w: got %q, want %q", ErrDomainMismatch, m.Domain, opts.Domain)
+	}
+	if opts.ChainID != 0 && m.ChainID != opts.ChainID {
+		return fmt.Errorf("%w: got %d, want %d", ErrChainIDMismatch, m.ChainID, opts.ChainID)
+	}
+
+	now := time.Now()
+	if opts.Now != nil {
+		now = opts.Now()
+	}
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return ErrExpired
+	}
+	if m.NotBefore != nil && now.Before(*m.NotBefore) {
+		return ErrNotYetValid
+	}
+
+	if opts.Verifier != nil {
+		valid, err := opts.Verifier.VerifySignature(ctx, m.Address, m.Hash(), sig)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return ErrInvalidSignature
+		}
+	} else {
+		recovered, err := sigverify.RecoverAddress(m.Hash(), sig)
+		if err != nil || recovered != m.Address {
+			return ErrInvalidSignature
+		}
+	}
+
+	if opts.Nonces == nil {
+		return errors.New("siwe: no nonce store configured")
+	}
+	return opts.Nonces.Consume(m.Nonce)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+	"transactiontypes/account"
+	"transactiontypes/builder"
+	"transactiontypes/erc20"
+	"transactiontypes/journal"
+	"transactiontypes/monitor"
+	"transactiontypes/policy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	// Public RPC URL for Polygon Amoy Testnet
+	NodeRPCURL  = "https://polygon-amoy.drpc.org"
+	AmoyChainID = 80002 // Polygon Amoy Testnet Chain ID
+
+	// Token used by the eip1559 and eip2930 examples
+	DefaultToken = "0x0fd9e8d3af1aaee056eb9e802c3a762a667b1904"
+)
+
+const usage = `Usage:
+  token [flags] info
+  token [flags] balance [address]
+  token [flags] allowance <owner> <spender>
+  token [flags] transfer <to> <amount>
+  token [flags] approve <spender> <amount>
+  token [flags] transfer-from <from> <to> <amount>
+
+Amounts are in token units, optionally followed by the symbol: 12.5 or "12.5 USDC".
+
+Flags:`
+
+func main() {
+	tokenAddr := flag.String("token", DefaultToken, "ERC-20 token address")
+	accNum := flag.Int("account", 2, "account number to send from")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	client, err := ethclient.Dial(NodeRPCURL)
+	if err != nil {
+		log.Fatal("Failed to connect to Ethereum node:", err)
+	}
+
+	// decimals() and symbol() tell us how to read and print amounts
+	token, err := erc20.NewToken(ctx, client, common.HexToAddress(*tokenAddr))
+	if err != nil {
+		log.Fatal("Failed to load token:", err)
+	}
+	sender, priv := account.GetAccount(*accNum)
+
+	switch args[0] {
+	case "info":
+		fmt.Println("Token:", token.Address.Hex())
+		fmt.Println("Symbol:", token.Symbol)
+		fmt.Println("Decimals:", token.Decimals)
+		printBalance(ctx, token, *sender)
+
+	case "balance":
+		owner := *sender
+		if len(args) > 1 {
+			owner = parseAddress(args[1])
+		}
+		printBalance(ctx, token, owner)
+
+	case "allowance":
+		if len(args) != 3 {
+			log.Fatal("Usage: token allowance <owner> <spender>")
+		}
+		allowance, err := token.Allowance(ctx, parseAddress(args[1]), parseAddress(args[2]))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Allowance:", token.FormatAmount(allowance))
+
+	case "transfer":
+		if len(args) < 3 {
+			log.Fatal("Usage: token transfer <to> <amount>")
+		}
+		to, amount := parseAddress(args[1]), parseAmount(token, args[2:])
+		// A transfer exceeding the balance reverts, don't pay gas for it
+		if err := token.CheckBalance(ctx, *sender, amount); err != nil {
+			log.Fatal(err)
+		}
+		data, err := erc20.PackTransfer(to, amount)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Transferring %s to %s\n", token.FormatAmount(amount), to.Hex())
+		send(ctx, client, token, *sender, priv, data)
+
+	case "approve":
+		if len(args) < 3 {
+			log.Fatal("Usage: token approve <spender> <amount>")
+		}
+		spender, amount := parseAddress(args[1]), parseAmount(token, args[2:])
+		current, err := token.Allowance(ctx, *sender, spender)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Changing a non-zero allowance lets the spender front-run the change and
+		// spend both values. Some tokens (USDT) reject it outright.
+		if current.Sign() != 0 && amount.Sign() != 0 {
+			fmt.Printf("Warning: replacing existing allowance of %s, consider approving 0 first\n", token.FormatAmount(current))
+		}
+		data, err := erc20.PackApprove(spender, amount)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Approving %s to spend %s\n", spender.Hex(), token.FormatAmount(amount))
+		send(ctx, client, token, *sender, priv, data)
+
+	case "transfer-from":
+		if len(args) < 4 {
+			log.Fatal("Usage: token transfer-from <from> <to> <amount>")
+		}
+		from, to, amount := parseAddress(args[1]), parseAddress(args[2]), parseAmount(token, args[3:])
+		// The sender spends on behalf of from, both the balance and the
+		// allowance have to cover the amount
+		if err := token.CheckBalance(ctx, from, amount); err != nil {
+			log.Fatal(err)
+		}
+		if err := token.CheckAllowance(ctx, from, *sender, amount); err != nil {
+			log.Fatal(err)
+		}
+		data, err := erc20.PackTransferFrom(from, to, amount)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Transferring %s from %s to %s\n", token.FormatAmount(amount), from.Hex(), to.Hex())
+		send(ctx, client, token, *sender, priv, data)
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// send calls the token with data in an EIP-1559 transaction and prints the
+// Transfer events of the receipt.
+func send(ctx context.Context, client *ethclient.Client, token *erc20.Token, from common.Address, priv *ecdsa.PrivateKey, data []byte) {
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		log.Fatal("Failed to fetch nonce:", err)
+	}
+
+	gasTipCap, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		log.Fatal("Failed to fetch gas tip cap:", err)
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		log.Fatal("Failed to fetch latest header:", err)
+	}
+	// GasFeeCap = baseFee + 12% + tip
+	gasFeeCap := new(big.Int).Mul(head.BaseFee, big.NewInt(112))
+	gasFeeCap.Div(gasFeeCap, big.NewInt(100))
+	gasFeeCap.Add(gasFeeCap, gasTipCap)
+
+	chainID := big.NewInt(AmoyChainID)
+	req := &builder.Request{
+		Type:      types.DynamicFeeTxType,
+		ChainID:   chainID,
+		From:      from,
+		To:        &token.Address,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Data:      data,
+	}
+	// Estimating through eth_createAccessList also surfaces reverts before sending
+	alResult, err := req.GenerateAccessList(ctx, client, builder.EnvAt(nil, head))
+	if err != nil {
+		log.Fatal("Failed to create access list:", err)
+	}
+	fmt.Println(alResult)
+
+	spending, err := policy.Default()
+	if err != nil {
+		log.Fatal("Failed to load spending policy:", err)
+	}
+	if err := spending.Check(req); err != nil {
+		log.Fatal(err)
+	}
+	tx, err := req.Build()
+	if err != nil {
+		log.Fatal("Failed to build transaction:", err)
+	}
+
+	signer := types.LatestSignerForChainID(chainID)
+	jrnl, err := journal.OpenDefault()
+	if err != nil {
+		log.Fatal("Failed to open journal:", err)
+	}
+	if err := jrnl.RecordBuilt(tx, from, signer); err != nil {
+		log.Fatal("Failed to journal transaction:", err)
+	}
+	signedTx, err := types.SignTx(tx, signer, priv)
+	if err != nil {
+		log.Fatal("Failed to sign transaction:", err)
+	}
+
+	err = jrnl.Broadcast(ctx, client, NodeRPCURL, signedTx, from)
+	if err != nil {
+		log.Fatal("Broadcast failed:", err)
+	}
+	fmt.Println("Transaction sent!")
+	fmt.Println("Tx hash:", signedTx.Hash().Hex())
+
+	// Optionally wait for inclusion
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	mined, err := monitor.Wait(waitCtx, client, signedTx, from, monitor.StatusIncluded)
+	if err != nil {
+		fmt.Println("Tx not mined yet:", err)
+		return
+	}
+	receipt := mined.Receipt
+	fmt.Println("Tx mined in block:", receipt.BlockNumber)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		fmt.Println("Tx reverted.")
+		return
+	}
+
+	transfers, err := erc20.DecodeTransfers(receipt.Logs)
+	if err != nil {
+		log.Fatal("Failed to decode logs:", err)
+	}
+	for _, t := range transfers {
+		// Other tokens may have moved too, their decimals are unknown here
+		amount := t.Value.String() + " base units of " + t.Token.Hex()
+		if t.Token == token.Address {
+			amount = token.FormatAmount(t.Value)
+		}
+		fmt.Printf("Transfer: %s -> %s: %s\n", t.From.Hex(), t.To.Hex(), amount)
+	}
+}
+
+func printBalance(ctx context.Context, token *erc20.Token, owner common.Address) {
+	balance, err := token.BalanceOf(ctx, owner)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Balance of %s: %s\n", owner.Hex(), token.FormatAmount(balance))
+}
+
+func parseAddress(s string) common.Address {
+	if !common.IsHexAddress(s) {
+		log.Fatalf("Invalid address: %s", s)
+	}
+	return common.HexToAddress(s)
+}
+
+// parseAmount accepts the amount as one argument ("12.5 USDC") or split over
+// two (12.5 USDC).
+func parseAmount(token *erc20.Token, args []string) *big.Int {
+	amount, err := token.ParseAmount(strings.Join(args, " "))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return amount
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"transactiontypes/builder"
+	"transactiontypes/policy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const usage = `Usage:
+  txconvert [-rpc url] [-auth file] [-sidecar file -blob-fee-cap amount]
+            <raw signed tx> <legacy|2930|1559|blob|setcode>
+
+Prints the transaction re-expressed as the target type, unsigned, and the
+fields that could not be carried over. With -rpc the current base fee is
+used to map between gas price and fee caps.
+
+A setcode target needs signed authorizations, -auth reads them as a JSON
+array of {chainId, address, nonce, yParity, r, s}. A blob target needs
+-sidecar, a JSON object {blobs, commitments, proofs} of hex strings as in a
+blob transaction's JSON, and -blob-fee-cap such as "30 gwei".`
+
+var txTypes = map[string]uint8{
+	"legacy":  types.LegacyTxType,
+	"2930":    types.AccessListTxType,
+	"1559":    types.DynamicFeeTxType,
+	"blob":    types.BlobTxType,
+	"setcode": types.SetCodeTxType,
+}
+
+func main() {
+	rpcURL := flag.String("rpc", "", "node to read the current base fee from")
+	authFile := flag.String("auth", "", "JSON file of signed authorizations to add")
+	sidecarFile := flag.String("sidecar", "", "JSON file of blobs, commitments and proofs to carry")
+	blobFeeCap := flag.String("blob-fee-cap", "", "max fee per blob gas, e.g. \"30 gwei\"")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(common.FromHex(flag.Arg(0))); err != nil {
+		log.Fatal("Failed to decode transaction:", err)
+	}
+	target, ok := txTypes[flag.Arg(1)]
+	if !ok {
+		log.Fatalf("Unknown transaction type %q", flag.Arg(1))
+	}
+
+	// The converted transaction keeps the sender, recover it from the signature
+	from, err := builder.Sender(tx)
+	if err != nil {
+		log.Fatal("Failed to recover sender:", err)
+	}
+
+	var add builder.Additions
+	if *authFile != "" {
+		if err := readJSON(*authFile, &add.AuthList); err != nil {
+			log.Fatal("Failed to read authorizations:", err)
+		}
+	}
+	if *sidecarFile != "" {
+		var sc sidecarJSON
+		if err := readJSON(*sidecarFile, &sc); err != nil {
+			log.Fatal("Failed to read sidecar:", err)
+		}
+		add.Sidecar = sc.sidecar()
+	}
+	if *blobFeeCap != "" {
+		if add.BlobFeeCap, err = policy.ParseWei(*blobFeeCap); err != nil {
+			log.Fatal("Invalid blob fee cap:", err)
+		}
+	}
+
+	var baseFee *big.Int
+	if *rpcURL != "" {
+		client, err := ethclient.Dial(*rpcURL)
+		if err != nil {
+			log.Fatal("Failed to connect to Ethereum node:", err)
+		}
+		head, err := client.HeaderByNumber(context.Background(), nil)
+		if err != nil {
+			log.Fatal("Failed to fetch latest header:", err)
+		}
+		baseFee = head.BaseFee
+	}
+
+	converted, losses, err := builder.ConvertTx(tx, from, target, baseFee, add)
+	if err != nil {
+		log.Fatal("Conversion failed:", err)
+	}
+
+	fmt.Printf("Converted type %d transaction from %s to type %d\n", tx.Type(), from.Hex(), target)
+	for _, loss := range losses {
+		fmt.Println("Dropped", loss)
+	}
+	out, err := json.MarshalIndent(converted, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+	// An unsigned legacy transaction doesn't know its chain ID, sign like the source
+	fmt.Println("Signing hash:", builder.SignerOf(tx).Hash(converted).Hex())
+}
+
+// sidecarJSON is a blob sidecar named like the fields of a blob
+// transaction's JSON.
+type sidecarJSON struct {
+	Blobs       []kzg4844.Blob       `json:"blobs"`
+	Commitments []kzg4844.Commitment `json:"commitments"`
+	Proofs      []kzg4844.Proof      `json:"proofs"`
+}
+
+// sidecar tells the version from the proofs: one per blob before Osaka,
+// one per cell after.
+func (s sidecarJSON) sidecar() *types.BlobTxSidecar {
+	var version byte
+	if len(s.Blobs) > 0 && len(s.Proofs) == len(s.Blobs)*kzg4844.CellProofsPerBlob {
+		version = 1
+	}
+	return &types.BlobTxSidecar{Version: version, Blobs: s.Blobs, Commitments: s.Commitments, Proofs: s.Proofs}
+}
+
+func readJSON(path string, out any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}